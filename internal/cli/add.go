@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func addCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "add",
+		Usage:     "create a worktree for a branch inside the current gitsej repo",
+		UsageText: "gitsej add [options] <branch> [directory]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "from",
+				Usage: "start point for a new branch (default: origin/<main_branch> from .gitsej)",
+			},
+		},
+		Action: runAdd,
+	}
+}
+
+func runAdd(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) < 1 || len(args) > 2 {
+		return cli.Exit("expected <branch> [directory]", 2)
+	}
+
+	worktreeDir := ""
+	if len(args) == 2 {
+		worktreeDir = strings.TrimSpace(args[1])
+	}
+
+	result, err := gitsej.AddWorktree(ctx, gitsej.AddWorktreeOptions{
+		Directory: ".",
+		Branch:    strings.TrimSpace(args[0]),
+		Path:      worktreeDir,
+		From:      strings.TrimSpace(c.String("from")),
+		Sparse:    strings.TrimSpace(c.String("sparse")),
+
+		HookOutput: outputWriter(c),
+	})
+	if err != nil {
+		return err
+	}
+
+	details := []string{"branch=" + result.Branch}
+	if result.CreatedBranch {
+		details = append(details, "from="+result.StartPoint)
+	} else {
+		details = append(details, "existing branch")
+	}
+	if result.Upstream != "" {
+		details = append(details, "upstream="+result.Upstream)
+	}
+	if result.SparseProfile != "" {
+		details = append(details, "sparse="+result.SparseProfile)
+	}
+
+	_, err = fmt.Fprintf(outputWriter(c), "added worktree: %s (%s)\n", result.Path, strings.Join(details, ", "))
+	return err
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/caarlos0/env/v11"
@@ -15,8 +17,10 @@ import (
 )
 
 type envDefaults struct {
-	MainWorktree bool   `env:"GITSEJ_MAIN_WORKTREE" envDefault:"false"`
-	MainBranch   string `env:"GITSEJ_MAIN_BRANCH" envDefault:"main"`
+	MainWorktree    bool   `env:"GITSEJ_MAIN_WORKTREE" envDefault:"false"`
+	MainWorktreeDir string `env:"GITSEJ_MAIN_WORKTREE_DIR"`
+	MainBranch      string `env:"GITSEJ_MAIN_BRANCH"`
+	PostWorktreeAdd string `env:"GITSEJ_POST_WORKTREE_ADD_HOOK"`
 }
 
 func NewCommand() *cli.Command {
@@ -24,7 +28,6 @@ func NewCommand() *cli.Command {
 	if err := env.Parse(&defaults); err != nil {
 		defaults = envDefaults{
 			MainWorktree: false,
-			MainBranch:   "main",
 		}
 	}
 
@@ -35,14 +38,44 @@ func NewCommand() *cli.Command {
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:  "main-worktree",
-				Usage: "create a main worktree checkout at <directory>/main",
+				Usage: "create a main worktree checkout at <directory>/<main-worktree-dir>",
 				Value: defaults.MainWorktree,
 			},
+			&cli.StringFlag{
+				Name:  "main-worktree-dir",
+				Usage: "main worktree directory, relative to the repo directory or absolute (default: main)",
+				Value: defaults.MainWorktreeDir,
+			},
 			&cli.StringFlag{
 				Name:  "main-branch",
-				Usage: "branch name for main worktree creation and .gitsej defaults",
+				Usage: "branch name for main worktree creation and .gitsej defaults (default: remote default branch for clones, else main)",
 				Value: defaults.MainBranch,
 			},
+			&cli.IntFlag{
+				Name:  "depth",
+				Usage: "shallow clone with the given number of commits; later fetches keep the depth",
+				Local: true,
+			},
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "partial clone filter: blob:none or tree:0 (the remote must support partial clone)",
+				Local: true,
+			},
+			&cli.BoolFlag{
+				Name:  "single-branch",
+				Usage: "clone and fetch only the main branch; other branches are fetched on gitsej add",
+				Local: true,
+			},
+			&cli.StringFlag{
+				Name:  "sparse",
+				Usage: "cone-mode sparse-checkout profile for new worktrees: <name> from .gitsej, or <name>=<dir>,<dir> to define it",
+			},
+			&cli.StringFlag{
+				Name:  "post-worktree-add-hook",
+				Usage: "shell command run in every new worktree; recorded in .gitsej",
+				Value: defaults.PostWorktreeAdd,
+				Local: true,
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -61,6 +94,49 @@ func NewCommand() *cli.Command {
 						Aliases: []string{"y"},
 						Usage:   "proceed even if main worktree has uncommitted changes",
 					},
+					&cli.BoolFlag{
+						Name:  "prune-missing",
+						Usage: "prune entries of linked worktrees whose directories no longer exist",
+					},
+					&cli.BoolFlag{
+						Name:  "carry-changes",
+						Usage: "move uncommitted and untracked changes into the new main worktree",
+					},
+					&cli.StringSliceFlag{
+						Name:  "keep-ignored",
+						Usage: "glob of ignored files to move into the main worktree instead of deleting (repeatable)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "print the migration plan without changing anything",
+					},
+					&cli.BoolFlag{
+						Name:  "recursive",
+						Usage: "migrate every standard clone found under <directory>",
+					},
+					&cli.IntFlag{
+						Name:  "max-depth",
+						Value: 3,
+						Usage: "with --recursive, how many directory levels to search",
+					},
+					&cli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   4,
+						Usage:   "with --recursive, how many clones to migrate at once",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "continue an interrupted migration",
+					},
+					&cli.BoolFlag{
+						Name:  "abort",
+						Usage: "roll back an interrupted migration",
+					},
+					&cli.StringFlag{
+						Name:  "post-worktree-add-hook",
+						Usage: "shell command run in every new worktree; recorded in .gitsej (default: $GITSEJ_POST_WORKTREE_ADD_HOOK unless .gitsej sets one)",
+					},
 				},
 				Action: runMigrate,
 			},
@@ -70,6 +146,16 @@ func NewCommand() *cli.Command {
 				UsageText: "gitsej upgrade [options] [directory]",
 				Action:    runUpgrade,
 			},
+			unmigrateCommand(),
+			addCommand(),
+			removeCommand(),
+			listCommand(),
+			syncCommand(),
+			rebaseCommand(),
+			statusCommand(),
+			configCommand(),
+			doctorCommand(),
+			daemonCommand(),
 		},
 		Action: runCreate,
 	}
@@ -87,10 +173,18 @@ func runCreate(ctx context.Context, c *cli.Command) error {
 	}
 
 	createdDir, err := gitsej.Create(ctx, gitsej.CreateOptions{
-		RepoURL:      strings.TrimSpace(args[0]),
-		Directory:    targetDir,
-		MainWorktree: c.Bool("main-worktree"),
-		MainBranch:   strings.TrimSpace(c.String("main-branch")),
+		RepoURL:         strings.TrimSpace(args[0]),
+		Directory:       targetDir,
+		MainWorktree:    c.Bool("main-worktree"),
+		MainWorktreeDir: strings.TrimSpace(c.String("main-worktree-dir")),
+		MainBranch:      strings.TrimSpace(c.String("main-branch")),
+		Depth:           int(c.Int("depth")),
+		Filter:          strings.TrimSpace(c.String("filter")),
+		SingleBranch:    c.Bool("single-branch"),
+		Sparse:          strings.TrimSpace(c.String("sparse")),
+
+		PostWorktreeAddHook: c.String("post-worktree-add-hook"),
+		HookOutput:          outputWriter(c),
 	})
 	if err != nil {
 		return err
@@ -112,8 +206,9 @@ func runInit(_ context.Context, c *cli.Command) error {
 	}
 
 	result, err := gitsej.Init(gitsej.InitOptions{
-		Directory:  targetDir,
-		MainBranch: strings.TrimSpace(c.String("main-branch")),
+		Directory:       targetDir,
+		MainBranch:      strings.TrimSpace(c.String("main-branch")),
+		MainWorktreeDir: strings.TrimSpace(c.String("main-worktree-dir")),
 	})
 	if err != nil {
 		return err
@@ -147,19 +242,63 @@ func runMigrate(ctx context.Context, c *cli.Command) error {
 		return cli.Exit("expected <directory>", 2)
 	}
 
+	if c.Bool("recursive") && (c.Bool("resume") || c.Bool("abort")) {
+		return cli.Exit("--recursive cannot be combined with --resume or --abort", 2)
+	}
+	if c.Bool("resume") && c.Bool("abort") {
+		return cli.Exit("--resume and --abort are mutually exclusive", 2)
+	}
+	if c.Bool("abort") {
+		if err := gitsej.AbortMigrate(ctx, strings.TrimSpace(args[0])); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(outputWriter(c), "aborted migration: %s\n", strings.TrimSpace(args[0]))
+		return err
+	}
+
 	opts := gitsej.MigrateOptions{
-		Directory:      strings.TrimSpace(args[0]),
-		ForceMainClean: c.Bool("yes"),
+		Directory:       strings.TrimSpace(args[0]),
+		MainWorktreeDir: strings.TrimSpace(c.String("main-worktree-dir")),
+		ForceMainClean:  c.Bool("yes"),
+		PruneMissing:    c.Bool("prune-missing"),
+		CarryChanges:    c.Bool("carry-changes"),
+		KeepIgnored:     c.StringSlice("keep-ignored"),
+		Sparse:          strings.TrimSpace(c.String("sparse")),
+		DryRun:          c.Bool("dry-run"),
+
+		PostWorktreeAddHook: c.String("post-worktree-add-hook"),
+	}
+	defaults := envDefaults{}
+	if err := env.Parse(&defaults); err == nil {
+		opts.DefaultPostWorktreeAddHook = defaults.PostWorktreeAdd
 	}
 	if c.IsSet("main-branch") {
 		opts.MainBranch = strings.TrimSpace(c.String("main-branch"))
 	}
 
-	result, err := gitsej.Migrate(ctx, opts)
+	if c.Bool("recursive") {
+		return runMigrateRecursive(ctx, c, opts)
+	}
+	// Recursive migrations run in parallel, so only a single one streams
+	// hook output.
+	opts.HookOutput = outputWriter(c)
+
+	var (
+		result gitsej.MigrateResult
+		err    error
+	)
+	if c.Bool("resume") {
+		result, err = gitsej.ResumeMigrate(ctx, opts.Directory, opts.HookOutput)
+	} else {
+		result, err = gitsej.Migrate(ctx, opts)
+	}
+	if err == nil && result.DryRun {
+		return printMigratePlan(c, result.Plan)
+	}
 	if err != nil {
 		var dirtyErr *gitsej.DirtyMainWorktreeError
 		if errors.As(err, &dirtyErr) && !opts.ForceMainClean {
-			confirmed, confirmErr := confirmMainCleanup(c, dirtyErr.Path)
+			confirmed, confirmErr := confirmMainCleanup(c, dirtyErr.Path, "migration")
 			if confirmErr != nil {
 				return confirmErr
 			}
@@ -194,10 +333,111 @@ func runMigrate(ctx context.Context, c *cli.Command) error {
 			return err
 		}
 	}
+	for _, lock := range result.LockedWorktrees {
+		reason := lock.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		if _, err := fmt.Fprintf(outputWriter(c), "locked worktree: %s (%s)\n", lock.Path, reason); err != nil {
+			return err
+		}
+	}
+	for _, path := range result.MissingWorktrees {
+		line := fmt.Sprintf("missing worktree: %s (reconnect with git worktree repair once it is back, or prune with gitsej doctor --fix)", path)
+		if slices.Contains(result.PrunedWorktrees, path) {
+			line = "pruned missing worktree: " + path
+		}
+		if _, err := fmt.Fprintln(outputWriter(c), line); err != nil {
+			return err
+		}
+	}
+	for _, rel := range result.CarriedIgnored {
+		if _, err := fmt.Fprintf(outputWriter(c), "kept ignored: %s\n", rel); err != nil {
+			return err
+		}
+	}
+	if result.CarriedChanges {
+		if len(result.CarryConflicts) == 0 {
+			_, err = fmt.Fprintf(outputWriter(c), "carried uncommitted changes into %s\n", result.CreatedMainWorktree)
+			return err
+		}
+		if _, err := fmt.Fprintf(
+			outputWriter(c),
+			"carried uncommitted changes into %s with conflicts (stash %s kept):\n",
+			result.CreatedMainWorktree,
+			result.CarryStash,
+		); err != nil {
+			return err
+		}
+		for _, path := range result.CarryConflicts {
+			if _, err := fmt.Fprintf(outputWriter(c), "conflict: %s\n", path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func printMigratePlan(c *cli.Command, plan gitsej.MigratePlan) error {
+	w := outputWriter(c)
+	lines := []string{
+		fmt.Sprintf("migration plan for %s (main_branch=%s)", plan.Directory, plan.MainBranch),
+		"convert .git/ to .bare/ and write .git file",
+	}
+	if plan.CreateConfig {
+		lines = append(lines, "create .gitsej")
+	}
+	if plan.PostWorktreeAddHook != "" {
+		lines = append(lines, fmt.Sprintf("set hook.post_worktree_add=%s in .gitsej", plan.PostWorktreeAddHook))
+	}
+	for _, entry := range plan.ConfigUpdates {
+		lines = append(lines, fmt.Sprintf("set %s=%s in .gitsej", entry.Key, entry.Value))
+	}
+	if plan.CarryChanges {
+		lines = append(lines, "stash uncommitted changes and apply them in the main worktree")
+	} else if plan.MainDirty {
+		lines = append(lines, "main worktree is dirty; uncommitted changes will be discarded")
+	}
+	for _, entry := range plan.RemoveRootEntries {
+		lines = append(lines, "remove "+filepath.Join(plan.Directory, entry))
+	}
+	for _, entry := range plan.KeptRootEntries {
+		lines = append(lines, "keep "+filepath.Join(plan.Directory, entry)+" (contains linked worktrees)")
+	}
+	if plan.Sparse.Define {
+		lines = append(lines, fmt.Sprintf("add sparse profile %s to .gitsej", plan.Sparse.Name))
+	}
+	lines = append(lines, fmt.Sprintf("create main worktree: %s (%s)", plan.MainWorktreePath, plan.MainBranch))
+	if plan.Sparse.Name != "" {
+		lines = append(lines, fmt.Sprintf("sparse checkout: %s (%s)", plan.Sparse.Name, strings.Join(plan.Sparse.Dirs, ", ")))
+	}
+	for _, rel := range plan.KeepIgnored {
+		lines = append(lines, "keep ignored: "+filepath.Join(plan.MainWorktreePath, rel))
+	}
+	for _, move := range plan.WorktreeMoves {
+		if move.Locked {
+			lines = append(lines, fmt.Sprintf("move locked worktree: %s -> %s", move.From, move.To))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("move worktree: %s -> %s", move.From, move.To))
+	}
+	for _, path := range plan.MissingWorktrees {
+		if slices.Contains(plan.PruneWorktrees, path) {
+			lines = append(lines, "prune missing worktree: "+path)
+			continue
+		}
+		lines = append(lines, "leave missing worktree: "+path)
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func runUpgrade(_ context.Context, c *cli.Command) error {
+func runUpgrade(ctx context.Context, c *cli.Command) error {
 	args := c.Args().Slice()
 	if len(args) > 1 {
 		return cli.Exit("expected [directory]", 2)
@@ -208,15 +448,16 @@ func runUpgrade(_ context.Context, c *cli.Command) error {
 		targetDir = strings.TrimSpace(args[0])
 	}
 
-	result, err := gitsej.Upgrade(gitsej.UpgradeOptions{
-		Directory:  targetDir,
-		MainBranch: strings.TrimSpace(c.String("main-branch")),
+	result, err := gitsej.Upgrade(ctx, gitsej.UpgradeOptions{
+		Directory:       targetDir,
+		MainBranch:      strings.TrimSpace(c.String("main-branch")),
+		MainWorktreeDir: strings.TrimSpace(c.String("main-worktree-dir")),
 	})
 	if err != nil {
 		return err
 	}
 
-	parts := make([]string, 0, 3)
+	parts := make([]string, 0, 4)
 	if result.CreatedGitFile {
 		parts = append(parts, "created .git")
 	}
@@ -226,6 +467,12 @@ func runUpgrade(_ context.Context, c *cli.Command) error {
 	if len(result.AddedKeys) > 0 {
 		parts = append(parts, "added keys: "+strings.Join(result.AddedKeys, ", "))
 	}
+	if result.ConfiguredFetchRefspec {
+		parts = append(parts, "configured origin fetch refspec")
+	}
+	if result.FetchErr != nil {
+		parts = append(parts, fmt.Sprintf("fetch from origin failed, run git fetch later: %v", result.FetchErr))
+	}
 	if len(parts) == 0 {
 		parts = append(parts, "no changes")
 	}
@@ -234,10 +481,11 @@ func runUpgrade(_ context.Context, c *cli.Command) error {
 	return err
 }
 
-func confirmMainCleanup(c *cli.Command, path string) (bool, error) {
+func confirmMainCleanup(c *cli.Command, path, operation string) (bool, error) {
 	if _, err := fmt.Fprintf(
 		outputWriter(c),
-		"main worktree is dirty and will be cleaned during migration: %s\ncontinue? [y/N]: ",
+		"main worktree is dirty and will be cleaned during %s: %s\ncontinue? [y/N]: ",
+		operation,
 		path,
 	); err != nil {
 		return false, err
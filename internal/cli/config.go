@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "config",
+		Usage:     "read and edit .gitsej settings of the current gitsej repo",
+		UsageText: "gitsej config list|get <key>|set <key> <value>|unset <key>",
+		Commands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "print effective .gitsej settings",
+				UsageText: "gitsej config list",
+				Action:    runConfigList,
+			},
+			{
+				Name:      "get",
+				Usage:     "print the effective value of a .gitsej key",
+				UsageText: "gitsej config get <key>",
+				Action:    runConfigGet,
+			},
+			{
+				Name:      "set",
+				Usage:     "validate and write a .gitsej key",
+				UsageText: "gitsej config set <key> <value>",
+				Action:    runConfigSet,
+			},
+			{
+				Name:      "unset",
+				Usage:     "remove a .gitsej key so its default applies",
+				UsageText: "gitsej config unset <key>",
+				Action:    runConfigUnset,
+			},
+		},
+	}
+}
+
+func runConfigList(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() > 0 {
+		return cli.Exit("expected no arguments", 2)
+	}
+
+	result, err := gitsej.ListConfig(ctx, gitsej.ConfigOptions{Directory: "."})
+	if err != nil {
+		return err
+	}
+	for _, entry := range result.Entries {
+		if _, err := fmt.Fprintf(outputWriter(c), "%s=%s\n", entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runConfigGet(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) != 1 {
+		return cli.Exit("expected <key>", 2)
+	}
+
+	value, err := gitsej.GetConfig(ctx, gitsej.ConfigOptions{
+		Directory: ".",
+		Key:       strings.TrimSpace(args[0]),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(outputWriter(c), value)
+	return err
+}
+
+func runConfigSet(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) != 2 {
+		return cli.Exit("expected <key> <value>", 2)
+	}
+
+	result, err := gitsej.SetConfig(ctx, gitsej.ConfigOptions{
+		Directory: ".",
+		Key:       strings.TrimSpace(args[0]),
+		Value:     args[1],
+	})
+	if err != nil {
+		return err
+	}
+	entry := result.Entries[0]
+	_, err = fmt.Fprintf(outputWriter(c), "updated %s: %s=%s\n", result.Path, entry.Key, entry.Value)
+	return err
+}
+
+func runConfigUnset(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) != 1 {
+		return cli.Exit("expected <key>", 2)
+	}
+
+	result, err := gitsej.UnsetConfig(ctx, gitsej.ConfigOptions{
+		Directory: ".",
+		Key:       strings.TrimSpace(args[0]),
+	})
+	if err != nil {
+		return err
+	}
+	entry := result.Entries[0]
+	_, err = fmt.Fprintf(outputWriter(c), "updated %s: unset %s (default %s=%s)\n", result.Path, entry.Key, entry.Key, entry.Value)
+	return err
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func daemonCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "daemon",
+		Usage:     "fetch registered gitsej repos in the background and cache their status",
+		UsageText: "gitsej daemon [options]\ngitsej daemon register|unregister [directory]\ngitsej daemon list",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 30 * time.Second,
+				Usage: "how often to check registered repos; each is fetched on its own cooldown",
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "log every refresh",
+			},
+		},
+		Action: runDaemon,
+		Commands: []*cli.Command{
+			{
+				Name:      "register",
+				Usage:     "add the gitsej repo containing [directory] to the daemon",
+				UsageText: "gitsej daemon register [directory]",
+				Action:    runDaemonRegister,
+			},
+			{
+				Name:      "unregister",
+				Usage:     "stop watching the gitsej repo containing [directory]",
+				UsageText: "gitsej daemon unregister [directory]",
+				Action:    runDaemonUnregister,
+			},
+			{
+				Name:      "list",
+				Usage:     "list registered gitsej repos",
+				UsageText: "gitsej daemon list",
+				Action:    runDaemonList,
+			},
+		},
+	}
+}
+
+func runDaemon(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() > 0 {
+		return cli.Exit("expected no arguments", 2)
+	}
+
+	defaults := loadStatusEnvDefaults()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := gitsej.DaemonOptions{
+		Interval:          c.Duration("interval"),
+		DefaultCooldown:   gitsej.ParseCooldown(defaults.Cooldown),
+		DefaultAutoUpdate: gitsej.ParseBool(defaults.AutoUpdate),
+
+		DefaultMainBranch:   strings.TrimSpace(defaults.MainBranch),
+		DefaultMainWorktree: defaults.mainWorktree(),
+		Log:                 os.Stderr,
+		Verbose:             c.Bool("verbose"),
+	}
+	return gitsej.RunDaemon(ctx, opts)
+}
+
+func runDaemonRegister(ctx context.Context, c *cli.Command) error {
+	dir, err := daemonDirectoryArg(c)
+	if err != nil {
+		return err
+	}
+	root, added, err := gitsej.RegisterRoot(ctx, "", dir)
+	if err != nil {
+		return err
+	}
+	if !added {
+		_, err = fmt.Fprintf(outputWriter(c), "already registered: %s\n", root)
+		return err
+	}
+	_, err = fmt.Fprintf(outputWriter(c), "registered: %s\n", root)
+	return err
+}
+
+func runDaemonUnregister(ctx context.Context, c *cli.Command) error {
+	dir, err := daemonDirectoryArg(c)
+	if err != nil {
+		return err
+	}
+	root, removed, err := gitsej.UnregisterRoot(ctx, "", dir)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("not registered: %s", root)
+	}
+	_, err = fmt.Fprintf(outputWriter(c), "unregistered: %s\n", root)
+	return err
+}
+
+func runDaemonList(_ context.Context, c *cli.Command) error {
+	if c.Args().Len() > 0 {
+		return cli.Exit("expected no arguments", 2)
+	}
+	roots, err := gitsej.RegisteredRoots("")
+	if err != nil {
+		return err
+	}
+	if pid, running := gitsej.DaemonRunning(""); running {
+		if _, err := fmt.Fprintf(outputWriter(c), "# daemon running (pid %d)\n", pid); err != nil {
+			return err
+		}
+	}
+	for _, root := range roots {
+		if _, err := fmt.Fprintln(outputWriter(c), root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func daemonDirectoryArg(c *cli.Command) (string, error) {
+	args := c.Args().Slice()
+	if len(args) > 1 {
+		return "", cli.Exit("expected [directory]", 2)
+	}
+	if len(args) == 1 {
+		return strings.TrimSpace(args[0]), nil
+	}
+	return ".", nil
+}
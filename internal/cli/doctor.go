@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "doctor",
+		Usage:     "validate a gitsej repo directory and optionally repair it",
+		UsageText: "gitsej doctor [options] [directory]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "fix",
+				Usage: "repair failing checks where possible",
+			},
+		},
+		Action: runDoctor,
+	}
+}
+
+func runDoctor(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) > 1 {
+		return cli.Exit("expected [directory]", 2)
+	}
+
+	targetDir := "."
+	if len(args) == 1 {
+		targetDir = strings.TrimSpace(args[0])
+	}
+
+	result, err := gitsej.Doctor(ctx, gitsej.DoctorOptions{
+		Directory: targetDir,
+		Fix:       c.Bool("fix"),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, check := range result.Checks {
+		line := ""
+		switch {
+		case check.Fixed:
+			line = fmt.Sprintf("fixed %s: %s", check.Name, check.Problem)
+		case check.OK:
+			line = fmt.Sprintf("ok    %s", check.Name)
+		case check.FixErr != nil:
+			line = fmt.Sprintf("FAIL  %s: %s (fix failed: %v)", check.Name, check.Problem, check.FixErr)
+		default:
+			line = fmt.Sprintf("FAIL  %s: %s", check.Name, check.Problem)
+		}
+		if _, err := fmt.Fprintln(outputWriter(c), line); err != nil {
+			return err
+		}
+	}
+
+	if failed := len(result.Failed()); failed > 0 {
+		hint := ""
+		if !c.Bool("fix") {
+			hint = "; run gitsej doctor --fix to repair"
+		}
+		return cli.Exit(fmt.Sprintf("%d check(s) failed in %s%s", failed, result.Directory, hint), 1)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func listCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Aliases:   []string{"ls"},
+		Usage:     "list worktrees of the current gitsej repo with branch and sync state",
+		UsageText: "gitsej list [options]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print worktrees as JSON",
+			},
+		},
+		Action: runList,
+	}
+}
+
+func runList(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() > 0 {
+		return cli.Exit("expected no arguments", 2)
+	}
+
+	result, err := gitsej.ListWorktrees(ctx, gitsej.ListOptions{Directory: "."})
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(outputWriter(c))
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	w := tabwriter.NewWriter(outputWriter(c), 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "WORKTREE\tBRANCH\tAHEAD\tBEHIND\tSTATE"); err != nil {
+		return err
+	}
+	for _, wt := range result.Worktrees {
+		name := wt.Path
+		if rel, err := filepath.Rel(result.Root, wt.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		if wt.Main {
+			name += "*"
+		}
+
+		branch := wt.Branch
+		if wt.Detached || branch == "" {
+			branch = "(detached " + shortHash(wt.Head) + ")"
+		}
+
+		ahead, behind := "-", "-"
+		if wt.Upstream != "" {
+			ahead = strconv.Itoa(wt.Ahead)
+			behind = strconv.Itoa(wt.Behind)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, branch, ahead, behind, worktreeState(wt)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func worktreeState(wt gitsej.WorktreeStatus) string {
+	states := make([]string, 0, 3)
+	switch {
+	case wt.Prunable:
+		states = append(states, "prunable")
+	case wt.Dirty:
+		states = append(states, "dirty")
+	default:
+		states = append(states, "clean")
+	}
+	if wt.Locked {
+		states = append(states, "locked")
+	}
+	if wt.Upstream == "" && wt.Branch != "" && !wt.Prunable {
+		states = append(states, "no upstream")
+	}
+	return strings.Join(states, ",")
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
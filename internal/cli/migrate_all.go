@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func runMigrateRecursive(ctx context.Context, c *cli.Command, opts gitsej.MigrateOptions) error {
+	result, err := gitsej.MigrateAll(ctx, gitsej.MigrateAllOptions{
+		Directory: opts.Directory,
+		MaxDepth:  int(c.Int("max-depth")),
+		Jobs:      int(c.Int("jobs")),
+		Migrate:   opts,
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Repos) == 0 {
+		_, err := fmt.Fprintf(outputWriter(c), "no clones found under %s\n", result.Directory)
+		return err
+	}
+
+	w := tabwriter.NewWriter(outputWriter(c), 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "REPO\tSTATUS\tDETAIL"); err != nil {
+		return err
+	}
+	for _, repo := range result.Repos {
+		name := repo.Directory
+		if rel, err := filepath.Rel(result.Directory, repo.Directory); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", name, repo.Status, migrationDetail(repo)); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	failed := result.Count(gitsej.MigrationFailed)
+	if _, err := fmt.Fprintf(
+		outputWriter(c),
+		"migrated %d, planned %d, skipped dirty %d, skipped gitsej %d, failed %d\n",
+		result.Count(gitsej.MigrationMigrated),
+		result.Count(gitsej.MigrationPlanned),
+		result.Count(gitsej.MigrationSkippedDirty),
+		result.Count(gitsej.MigrationSkippedRoot),
+		failed,
+	); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d migrations failed", failed, len(result.Repos))
+	}
+	return nil
+}
+
+func migrationDetail(repo gitsej.RepoMigration) string {
+	var dirtyErr *gitsej.DirtyMainWorktreeError
+	switch {
+	case errors.As(repo.Err, &dirtyErr):
+		return "uncommitted changes (use --yes or --carry-changes)"
+	case repo.Err != nil:
+		return strings.ReplaceAll(repo.Err.Error(), "\n", " ")
+	case repo.Status == gitsej.MigrationSkippedRoot:
+		return "already a gitsej repo"
+	case repo.Status == gitsej.MigrationMigrated || repo.Status == gitsej.MigrationPlanned:
+		return "main_branch=" + repo.Result.MainBranch
+	}
+	return ""
+}
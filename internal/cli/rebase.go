@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func rebaseCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "rebase",
+		Usage:     "rebase feature worktrees onto origin/<main_branch>",
+		UsageText: "gitsej rebase [options] (--all | <worktree>)",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "rebase every worktree except the main branch",
+			},
+			&cli.BoolFlag{
+				Name:  "autostash",
+				Usage: "stash uncommitted changes before rebasing and reapply them afterwards",
+			},
+		},
+		Action: runRebase,
+	}
+}
+
+func runRebase(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) > 1 || (len(args) == 0) == !c.Bool("all") {
+		return cli.Exit("expected --all or <worktree>", 2)
+	}
+
+	worktree := ""
+	if len(args) == 1 {
+		worktree = strings.TrimSpace(args[0])
+	}
+
+	result, err := gitsej.Rebase(ctx, gitsej.RebaseOptions{
+		Directory: ".",
+		Worktree:  worktree,
+		All:       c.Bool("all"),
+		AutoStash: c.Bool("autostash"),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(outputWriter(c), 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "WORKTREE\tBRANCH\tSTATUS\tDETAIL"); err != nil {
+		return err
+	}
+	for _, wt := range result.Worktrees {
+		name := wt.Path
+		if rel, err := filepath.Rel(result.Root, wt.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, wt.Branch, wt.Status, rebaseDetail(wt, result.Onto)); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	conflicts := result.Count(gitsej.RebaseConflict)
+	failed := result.Count(gitsej.RebaseFailed)
+	if _, err := fmt.Fprintf(
+		outputWriter(c),
+		"rebased %d, up to date %d, conflicts %d, skipped dirty %d, skipped %d, failed %d\n",
+		result.Count(gitsej.RebaseRebased),
+		result.Count(gitsej.RebaseUpToDate),
+		conflicts,
+		result.Count(gitsej.RebaseDirty),
+		result.Count(gitsej.RebaseSkipped),
+		failed,
+	); err != nil {
+		return err
+	}
+	if conflicts+failed > 0 {
+		return fmt.Errorf("%d of %d worktrees did not rebase cleanly onto %s", conflicts+failed, len(result.Worktrees), result.Onto)
+	}
+	return nil
+}
+
+func rebaseDetail(wt gitsej.WorktreeRebase, onto string) string {
+	switch wt.Status {
+	case gitsej.RebaseRebased:
+		return fmt.Sprintf("onto %s (%s behind)", onto, commitCount(wt.Commits))
+	case gitsej.RebaseUpToDate:
+		return onto
+	case gitsej.RebaseConflict:
+		return "conflicts in " + strings.Join(wt.Conflicts, ", ") + "; resolve and run git rebase --continue"
+	case gitsej.RebaseFailed:
+		if wt.Err != nil {
+			return strings.ReplaceAll(wt.Err.Error(), "\n", " ")
+		}
+	}
+	return wt.Reason
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func removeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "rm",
+		Aliases:   []string{"remove"},
+		Usage:     "remove a worktree from the current gitsej repo",
+		UsageText: "gitsej rm [options] <worktree>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "force",
+				Aliases: []string{"f"},
+				Usage:   "remove the worktree even if it has uncommitted changes",
+			},
+			&cli.BoolFlag{
+				Name:    "delete-branch",
+				Aliases: []string{"d"},
+				Usage:   "also delete the local branch when it is fully merged into main_branch",
+			},
+		},
+		Action: runRemove,
+	}
+}
+
+func runRemove(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) != 1 {
+		return cli.Exit("expected <worktree>", 2)
+	}
+
+	result, err := gitsej.RemoveWorktree(ctx, gitsej.RemoveWorktreeOptions{
+		Directory:    ".",
+		Worktree:     strings.TrimSpace(args[0]),
+		Force:        c.Bool("force"),
+		DeleteBranch: c.Bool("delete-branch"),
+	})
+	if err != nil {
+		var dirtyErr *gitsej.DirtyWorktreeError
+		if errors.As(err, &dirtyErr) {
+			return fmt.Errorf("%w (use --force to remove anyway)", err)
+		}
+		return err
+	}
+
+	if result.DeletedBranch {
+		_, err = fmt.Fprintf(outputWriter(c), "removed worktree: %s (deleted branch %s)\n", result.Path, result.Branch)
+		return err
+	}
+	_, err = fmt.Fprintf(outputWriter(c), "removed worktree: %s\n", result.Path)
+	return err
+}
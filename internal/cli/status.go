@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caarlos0/env/v11"
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	"github.com/repsejnworb/gitsej/internal/tmux"
+	cli "github.com/urfave/cli/v3"
+)
+
+type statusEnvDefaults struct {
+	// Every field is a string so one malformed variable cannot make the
+	// others fall back to their defaults; Cooldown is parsed on its own.
+	Cooldown      string `env:"GITSEJ_TMUX_COOLDOWN"`
+	AutoUpdate    string `env:"GITSEJ_AUTO_UPDATE" envDefault:"0"`
+	RequireMarker string `env:"GITSEJ_REQUIRE_MARKER" envDefault:"0"`
+	MainBranch    string `env:"GITSEJ_MAIN_BRANCH"`
+	// MainWorktree is the main worktree directory in the tmux script's
+	// GITSEJ_MAIN_WORKTREE, which gitsej itself reads as a boolean.
+	MainWorktree    string `env:"GITSEJ_MAIN_WORKTREE"`
+	MainWorktreeDir string `env:"GITSEJ_MAIN_WORKTREE_DIR"`
+}
+
+// mainWorktree returns the main worktree directory to use when .gitsej does
+// not set main_worktree: GITSEJ_MAIN_WORKTREE_DIR, or GITSEJ_MAIN_WORKTREE
+// unless it holds the boolean that gitsej <repo-url> reads from it.
+func (d statusEnvDefaults) mainWorktree() string {
+	if dir := strings.TrimSpace(d.MainWorktreeDir); dir != "" {
+		return dir
+	}
+	dir := strings.TrimSpace(d.MainWorktree)
+	if _, err := strconv.ParseBool(dir); err == nil {
+		return ""
+	}
+	return dir
+}
+
+// loadStatusEnvDefaults reads the status defaults from the environment. With
+// string fields only, parsing cannot fail as a whole.
+func loadStatusEnvDefaults() statusEnvDefaults {
+	defaults := statusEnvDefaults{}
+	_ = env.Parse(&defaults)
+	return defaults
+}
+
+func statusCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "status",
+		Usage:     "print tmux status for the main worktree of the session's gitsej repo",
+		UsageText: "gitsej status [options] [session-id]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "force",
+				Aliases: []string{"f"},
+				Usage:   "recompute now, ignoring cooldown (no pull unless auto_update=1)",
+			},
+			&cli.BoolFlag{
+				Name:  "update",
+				Usage: "fetch now and pull the main worktree when clean and behind",
+			},
+			&cli.BoolFlag{
+				Name:  "cycle",
+				Usage: "pin the next gitsej repo found in the session's panes",
+			},
+			&cli.BoolFlag{
+				Name:  "clear-pin",
+				Usage: "clear the pinned gitsej repo and return to auto-selection",
+			},
+		},
+		Action: runStatus,
+	}
+}
+
+// runStatus never fails on git or tmux errors: anything it cannot determine is
+// rendered as an empty status so the tmux status bar stays clean.
+func runStatus(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) > 1 {
+		return cli.Exit("expected [session-id]", 2)
+	}
+
+	defaults := loadStatusEnvDefaults()
+	requireMarker := strings.TrimSpace(defaults.RequireMarker) == "1"
+
+	if !tmux.Available() {
+		return nil
+	}
+
+	session := ""
+	if len(args) == 1 {
+		session = strings.TrimSpace(args[0])
+	}
+	if session == "" {
+		session, _ = tmux.CurrentSession(ctx)
+	}
+	if session == "" {
+		return nil
+	}
+
+	candidates := make([]string, 0, 4)
+	addCandidate := func(path string) string {
+		root, err := gitsej.ResolveRoot(ctx, path)
+		if err != nil || !gitsej.IsRoot(root, requireMarker) {
+			return ""
+		}
+		if !slices.Contains(candidates, root) {
+			candidates = append(candidates, root)
+		}
+		return root
+	}
+
+	activeRoot := ""
+	if activePath, err := tmux.ActivePanePath(ctx, session); err == nil && activePath != "" {
+		activeRoot = addCandidate(activePath)
+	}
+	if panePaths, err := tmux.PanePaths(ctx, session); err == nil {
+		for _, path := range panePaths {
+			addCandidate(path)
+		}
+	}
+
+	if c.Bool("clear-pin") {
+		_ = tmux.UnsetOption(ctx, session, tmux.RootOption)
+		return nil
+	}
+
+	pinnedRoot := tmux.Option(ctx, session, tmux.RootOption)
+
+	if c.Bool("cycle") {
+		next := nextCandidate(candidates, pinnedRoot)
+		if next == "" {
+			_ = tmux.UnsetOption(ctx, session, tmux.RootOption)
+			return nil
+		}
+		_ = tmux.SetOption(ctx, session, tmux.RootOption, next)
+		return nil
+	}
+
+	selectedRoot := ""
+	switch {
+	case gitsej.IsRoot(pinnedRoot, requireMarker):
+		selectedRoot = pinnedRoot
+	case activeRoot != "":
+		selectedRoot = activeRoot
+	case len(candidates) > 0:
+		selectedRoot = candidates[0]
+	default:
+		return nil
+	}
+	_ = tmux.SetOption(ctx, session, tmux.RootOption, selectedRoot)
+
+	result, err := gitsej.MainStatus(ctx, gitsej.MainStatusOptions{
+		Root:              selectedRoot,
+		CacheOnly:         daemonWatches(selectedRoot),
+		Force:             c.Bool("force"),
+		Update:            c.Bool("update"),
+		DefaultCooldown:   gitsej.ParseCooldown(defaults.Cooldown),
+		DefaultAutoUpdate: gitsej.ParseBool(defaults.AutoUpdate),
+
+		DefaultMainBranch:   strings.TrimSpace(defaults.MainBranch),
+		DefaultMainWorktree: defaults.mainWorktree(),
+	})
+	if err != nil {
+		return nil
+	}
+
+	_, err = fmt.Fprint(outputWriter(c), tmuxStatusLine(result))
+	return err
+}
+
+// daemonWatches reports whether a running gitsej daemon keeps root's status
+// cache fresh, so status can read it without fetching.
+func daemonWatches(root string) bool {
+	if _, running := gitsej.DaemonRunning(""); !running {
+		return false
+	}
+	roots, err := gitsej.RegisteredRoots("")
+	return err == nil && slices.Contains(roots, root)
+}
+
+// nextCandidate returns the candidate after pinned, wrapping around, or the
+// first candidate when pinned is not one of them.
+func nextCandidate(candidates []string, pinned string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	idx := slices.Index(candidates, pinned)
+	if idx < 0 || idx == len(candidates)-1 {
+		return candidates[0]
+	}
+	return candidates[idx+1]
+}
+
+func tmuxStatusLine(result gitsej.MainStatusResult) string {
+	base := fmt.Sprintf(" %s: %s", result.Label, result.MainBranch)
+	switch {
+	case result.Dirty && result.Behind > 0:
+		return fmt.Sprintf("#[fg=#f7768e]%s ! +%d#[fg=#a9b1d6] | ", base, result.Behind)
+	case result.Dirty:
+		return fmt.Sprintf("#[fg=#e0af68]%s !#[fg=#a9b1d6] | ", base)
+	case result.Behind > 0:
+		return fmt.Sprintf("#[fg=#e0af68]%s +%d#[fg=#a9b1d6] | ", base, result.Behind)
+	default:
+		return fmt.Sprintf("#[fg=#9ece6a]%s ✓#[fg=#a9b1d6] | ", base)
+	}
+}
@@ -0,0 +1,16 @@
+package cli
+
+import "testing"
+
+func TestLoadStatusEnvDefaultsKeepsValidVariables(t *testing.T) {
+	t.Setenv("GITSEJ_TMUX_COOLDOWN", "abc")
+	t.Setenv("GITSEJ_AUTO_UPDATE", "1")
+	t.Setenv("GITSEJ_MAIN_BRANCH", "develop")
+	t.Setenv("GITSEJ_MAIN_WORKTREE", "dev")
+
+	// Like the tmux script, a malformed cooldown only affects the cooldown.
+	defaults := loadStatusEnvDefaults()
+	if defaults.AutoUpdate != "1" || defaults.MainBranch != "develop" || defaults.mainWorktree() != "dev" {
+		t.Fatalf("unexpected defaults: %+v", defaults)
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func syncCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "sync",
+		Usage:     "fetch once and fast-forward every clean worktree to its upstream",
+		UsageText: "gitsej sync [directory]",
+		Action:    runSync,
+	}
+}
+
+func runSync(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) > 1 {
+		return cli.Exit("expected [directory]", 2)
+	}
+
+	targetDir := "."
+	if len(args) == 1 {
+		targetDir = strings.TrimSpace(args[0])
+	}
+
+	result, err := gitsej.Sync(ctx, gitsej.SyncOptions{Directory: targetDir})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(outputWriter(c), 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "WORKTREE\tBRANCH\tSTATUS\tDETAIL"); err != nil {
+		return err
+	}
+	for _, wt := range result.Worktrees {
+		name := wt.Path
+		if rel, err := filepath.Rel(result.Root, wt.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, wt.Branch, wt.Status, syncDetail(wt)); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	failed := result.Count(gitsej.SyncFailed)
+	if _, err := fmt.Fprintf(
+		outputWriter(c),
+		"updated %d, up to date %d, skipped dirty %d, diverged %d, no upstream %d, failed %d\n",
+		result.Count(gitsej.SyncUpdated),
+		result.Count(gitsej.SyncUpToDate),
+		result.Count(gitsej.SyncDirty),
+		result.Count(gitsej.SyncDiverged),
+		result.Count(gitsej.SyncNoUpstream),
+		failed,
+	); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d worktrees failed to sync", failed, len(result.Worktrees))
+	}
+	return nil
+}
+
+func syncDetail(wt gitsej.WorktreeSync) string {
+	switch wt.Status {
+	case gitsej.SyncUpdated:
+		return fmt.Sprintf("fast-forwarded %s to %s", commitCount(wt.Commits), wt.Upstream)
+	case gitsej.SyncUpToDate:
+		if wt.Ahead > 0 {
+			return fmt.Sprintf("%s ahead of %s", commitCount(wt.Ahead), wt.Upstream)
+		}
+		return wt.Upstream
+	case gitsej.SyncDirty:
+		return fmt.Sprintf("uncommitted changes; %s behind %s", commitCount(wt.Commits), wt.Upstream)
+	case gitsej.SyncDiverged:
+		return fmt.Sprintf("%d ahead, %d behind %s; rebase or merge by hand", wt.Ahead, wt.Commits, wt.Upstream)
+	case gitsej.SyncNoUpstream:
+		return "branch has no upstream"
+	case gitsej.SyncDetached:
+		return "detached HEAD"
+	case gitsej.SyncMissing:
+		return "worktree directory is missing"
+	case gitsej.SyncFailed:
+		if wt.Err != nil {
+			return strings.ReplaceAll(wt.Err.Error(), "\n", " ")
+		}
+	}
+	return ""
+}
+
+func commitCount(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", n)
+}
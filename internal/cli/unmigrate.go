@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/repsejnworb/gitsej/internal/gitsej"
+	cli "github.com/urfave/cli/v3"
+)
+
+func unmigrateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "unmigrate",
+		Usage:     "convert a gitsej repo directory back into a standard clone",
+		UsageText: "gitsej unmigrate [options] <directory>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "proceed even if main worktree has uncommitted changes",
+			},
+			&cli.BoolFlag{
+				Name:  "keep-worktrees",
+				Usage: "keep linked worktrees inside the clone instead of moving them next to it",
+			},
+		},
+		Action: runUnmigrate,
+	}
+}
+
+func runUnmigrate(ctx context.Context, c *cli.Command) error {
+	args := c.Args().Slice()
+	if len(args) != 1 {
+		return cli.Exit("expected <directory>", 2)
+	}
+
+	opts := gitsej.UnmigrateOptions{
+		Directory:      strings.TrimSpace(args[0]),
+		ForceMainClean: c.Bool("yes"),
+		KeepWorktrees:  c.Bool("keep-worktrees"),
+	}
+
+	result, err := gitsej.Unmigrate(ctx, opts)
+	if err != nil {
+		var dirtyErr *gitsej.DirtyMainWorktreeError
+		if errors.As(err, &dirtyErr) && !opts.ForceMainClean {
+			confirmed, confirmErr := confirmMainCleanup(c, dirtyErr.Path, "unmigrate")
+			if confirmErr != nil {
+				return confirmErr
+			}
+			if !confirmed {
+				return errors.New("unmigrate canceled")
+			}
+			opts.ForceMainClean = true
+			result, err = gitsej.Unmigrate(ctx, opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(
+		outputWriter(c),
+		"unmigrated gitsej repo: %s (branch=%s, moved_worktrees=%d)\n",
+		result.Directory,
+		result.Branch,
+		len(result.MovedWorktrees),
+	); err != nil {
+		return err
+	}
+	for _, moved := range result.MovedWorktrees {
+		if _, err := fmt.Fprintf(outputWriter(c), "moved worktree: %s\n", moved); err != nil {
+			return err
+		}
+	}
+	for _, kept := range result.KeptWorktrees {
+		if _, err := fmt.Fprintf(outputWriter(c), "kept worktree: %s\n", kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type AddWorktreeOptions struct {
+	Directory string
+	Branch    string
+	Path      string
+	From      string
+	// Sparse names a sparse.<name> profile from .gitsej, or defines one
+	// inline as <name>=<dir>,<dir>.
+	Sparse string
+	// HookOutput receives the output of the post_worktree_add hooks.
+	HookOutput io.Writer
+}
+
+type AddWorktreeResult struct {
+	Root          string
+	Path          string
+	Branch        string
+	StartPoint    string
+	CreatedBranch bool
+	Upstream      string
+	SparseProfile string
+}
+
+func AddWorktree(ctx context.Context, opts AddWorktreeOptions) (AddWorktreeResult, error) {
+	branch := strings.TrimSpace(opts.Branch)
+	if branch == "" {
+		return AddWorktreeResult{}, errors.New("branch name is required")
+	}
+	if err := runGit(ctx, "check-ref-format", "--branch", branch); err != nil {
+		return AddWorktreeResult{}, fmt.Errorf("invalid branch name %q", branch)
+	}
+
+	root, err := ResolveRoot(ctx, opts.Directory)
+	if err != nil {
+		return AddWorktreeResult{}, err
+	}
+	lock, err := lockRoot(ctx, root, "add")
+	if err != nil {
+		return AddWorktreeResult{}, err
+	}
+	defer lock.Unlock()
+
+	worktreePath, err := worktreePathInRoot(root, opts.Path, branch)
+	if err != nil {
+		return AddWorktreeResult{}, err
+	}
+	if _, err := os.Stat(worktreePath); err == nil {
+		return AddWorktreeResult{}, fmt.Errorf("directory already exists: %s", worktreePath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return AddWorktreeResult{}, fmt.Errorf("check directory %s: %w", worktreePath, err)
+	}
+
+	cfg, cfgErr := loadConfig(root)
+	if cfgErr != nil && strings.TrimSpace(opts.Sparse) != "" {
+		return AddWorktreeResult{}, cfgErr
+	}
+	sparse, err := resolveSparseProfile(cfg, opts.Sparse)
+	if err != nil {
+		return AddWorktreeResult{}, err
+	}
+
+	result := AddWorktreeResult{
+		Root:          root,
+		Path:          worktreePath,
+		Branch:        branch,
+		SparseProfile: sparse.Name,
+	}
+
+	from := strings.TrimSpace(opts.From)
+	localExists := gitRefExists(ctx, root, "refs/heads/"+branch)
+	remoteExists := gitRefExists(ctx, root, "refs/remotes/origin/"+branch)
+	if !localExists && !remoteExists {
+		// Single-branch roots only track main_branch; look the branch up on
+		// origin before treating it as new.
+		if cfgErr == nil && cfg.SingleBranch {
+			remoteExists = fetchOriginBranch(ctx, root, cfg, branch)
+		}
+	}
+
+	switch {
+	case localExists:
+		if from != "" {
+			return AddWorktreeResult{}, fmt.Errorf("branch %s already exists; --from only applies to new branches", branch)
+		}
+		if err := runGit(ctx, worktreeAddArgs([]string{"-C", root}, sparse, worktreePath, branch)...); err != nil {
+			return AddWorktreeResult{}, fmt.Errorf("create worktree for %s: %w", branch, err)
+		}
+		result.StartPoint = branch
+	case remoteExists:
+		if from != "" {
+			return AddWorktreeResult{}, fmt.Errorf("branch %s already exists on origin; --from only applies to new branches", branch)
+		}
+		originRef := "origin/" + branch
+		if err := runGit(ctx, worktreeAddArgs([]string{"-C", root}, sparse, "-b", branch, worktreePath, originRef)...); err != nil {
+			return AddWorktreeResult{}, fmt.Errorf("create worktree from %s: %w", originRef, err)
+		}
+		result.StartPoint = originRef
+		result.CreatedBranch = true
+	default:
+		if from == "" {
+			from, err = defaultStartPoint(ctx, root)
+			if err != nil {
+				return AddWorktreeResult{}, err
+			}
+		}
+		if err := runGit(ctx, "-C", root, "rev-parse", "--verify", "--quiet", from+"^{commit}"); err != nil {
+			return AddWorktreeResult{}, fmt.Errorf("start point not found: %s", from)
+		}
+		if err := runGit(ctx, worktreeAddArgs([]string{"-C", root}, sparse, "--no-track", "-b", branch, worktreePath, from)...); err != nil {
+			return AddWorktreeResult{}, fmt.Errorf("create worktree from %s: %w", from, err)
+		}
+		result.StartPoint = from
+		result.CreatedBranch = true
+	}
+
+	if err := applySparseCheckout(ctx, worktreePath, sparse); err != nil {
+		return result, err
+	}
+	if err := saveSparseProfile(root, sparse); err != nil {
+		return result, err
+	}
+
+	// Like createMainWorktree, track origin/<branch>. New branches get no
+	// upstream until their first push: tracking the start point would make
+	// git push and sync treat origin/<main_branch> as the branch's upstream.
+	if remoteExists {
+		upstream := "origin/" + branch
+		if err := runGit(ctx, "-C", worktreePath, "branch", "--set-upstream-to", upstream, branch); err == nil {
+			result.Upstream = upstream
+		}
+	}
+
+	if err := initSubmodules(ctx, root, worktreePath); err != nil {
+		return result, err
+	}
+
+	// Hooks may run gitsej commands against the root.
+	lock.Unlock()
+	if err := runPostWorktreeAddHooks(ctx, root, cfg, worktreePath, branch, opts.HookOutput); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// defaultStartPoint returns the ref new branches are created from: the
+// remote-tracking main branch when available, otherwise the local one.
+func defaultStartPoint(ctx context.Context, root string) (string, error) {
+	mainBranch, err := configuredMainBranch(root)
+	if err != nil {
+		return "", err
+	}
+
+	if gitRefExists(ctx, root, "refs/remotes/origin/"+mainBranch) {
+		return "origin/" + mainBranch, nil
+	}
+	return mainBranch, nil
+}
+
+func worktreePathInRoot(root, dir, branch string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = strings.ReplaceAll(branch, "/", "-")
+	}
+
+	worktreePath := dir
+	if !filepath.IsAbs(worktreePath) {
+		worktreePath = filepath.Join(root, worktreePath)
+	}
+	worktreePath = filepath.Clean(worktreePath)
+
+	rel, err := filepath.Rel(root, worktreePath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("worktree path must be inside gitsej root %s: %s", root, dir)
+	}
+	if first := strings.Split(rel, string(os.PathSeparator))[0]; first == ".bare" || first == ".git" || first == ".gitsej" || first == hooksDirName {
+		return "", fmt.Errorf("worktree path is reserved: %s", dir)
+	}
+	return worktreePath, nil
+}
@@ -0,0 +1,178 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddWorktreeCreatesBranchFromMain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	result, err := AddWorktree(ctx, AddWorktreeOptions{
+		Directory: root,
+		Branch:    "feature/login",
+	})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	if got, want := result.Path, filepath.Join(root, "feature-login"); got != want {
+		t.Fatalf("result.Path = %q, want %q", got, want)
+	}
+	if !result.CreatedBranch {
+		t.Fatalf("expected branch to be created")
+	}
+	if got, want := result.StartPoint, "origin/main"; got != want {
+		t.Fatalf("result.StartPoint = %q, want %q", got, want)
+	}
+	if result.Upstream != "" {
+		t.Fatalf("expected no upstream for new branch, got %q", result.Upstream)
+	}
+	if _, err := runGitTestOutput(ctx, "-C", result.Path, "rev-parse", "--abbrev-ref", "@{upstream}"); err == nil {
+		t.Fatalf("expected new branch to have no upstream configured")
+	}
+
+	head, err := runGitTestOutput(ctx, "-C", result.Path, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("worktree branch: %v", err)
+	}
+	if strings.TrimSpace(head) != "feature/login" {
+		t.Fatalf("worktree HEAD = %q, want feature/login", strings.TrimSpace(head))
+	}
+}
+
+func TestAddWorktreeReusesRemoteBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	runGitTest(t, ctx, "-C", root, "branch", "-D", "develop")
+
+	result, err := AddWorktree(ctx, AddWorktreeOptions{
+		Directory: root,
+		Branch:    "develop",
+		Path:      "dev",
+	})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	if got, want := result.Path, filepath.Join(root, "dev"); got != want {
+		t.Fatalf("result.Path = %q, want %q", got, want)
+	}
+	if !result.CreatedBranch {
+		t.Fatalf("expected local branch to be created from origin/develop")
+	}
+	if got, want := result.Upstream, "origin/develop"; got != want {
+		t.Fatalf("result.Upstream = %q, want %q", got, want)
+	}
+
+	upstream, err := runGitTestOutput(ctx, "-C", result.Path, "rev-parse", "--abbrev-ref", "develop@{upstream}")
+	if err != nil {
+		t.Fatalf("upstream: %v", err)
+	}
+	if strings.TrimSpace(upstream) != "origin/develop" {
+		t.Fatalf("upstream = %q, want origin/develop", strings.TrimSpace(upstream))
+	}
+}
+
+func TestAddWorktreeReusesLocalBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	runGitTest(t, ctx, "-C", root, "branch", "local-only", "origin/main")
+
+	result, err := AddWorktree(ctx, AddWorktreeOptions{
+		Directory: root,
+		Branch:    "local-only",
+	})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	if result.CreatedBranch {
+		t.Fatalf("did not expect branch creation for existing local branch")
+	}
+
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{
+		Directory: root,
+		Branch:    "local-only",
+		Path:      "other",
+		From:      "origin/main",
+	}); err == nil {
+		t.Fatalf("expected error when --from is used with an existing branch")
+	}
+}
+
+func TestAddWorktreeFromLocalRefHasNoUpstream(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	runGitTest(t, ctx, "-C", root, "branch", "base", "origin/main")
+
+	result, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "topic", From: "base"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	if result.Upstream != "" {
+		t.Fatalf("expected no upstream for a branch cut from a local ref, got %q", result.Upstream)
+	}
+	if _, err := runGitTestOutput(ctx, "-C", result.Path, "rev-parse", "--abbrev-ref", "@{upstream}"); err == nil {
+		t.Fatalf("expected topic to have no upstream configured")
+	}
+}
+
+func TestAddWorktreeRejectsPathOutsideRoot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	for _, dir := range []string{"../escape", ".bare/nested", filepath.Dir(root)} {
+		if _, err := AddWorktree(ctx, AddWorktreeOptions{
+			Directory: root,
+			Branch:    "escape",
+			Path:      dir,
+		}); err == nil {
+			t.Fatalf("expected error for worktree path %q", dir)
+		}
+	}
+}
+
+// newTestGitsejRoot creates a gitsej root backed by a local origin with main
+// and develop branches, with remote-tracking refs populated.
+func newTestGitsejRoot(t *testing.T, ctx context.Context) string {
+	t.Helper()
+
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	root := filepath.Join(base, "repo")
+
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	if err := os.WriteFile(filepath.Join(origin, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	runGitTest(t, ctx, "-C", origin, "add", "README.md")
+	runGitTest(t, ctx, "-C", origin, "commit", "-m", "init")
+	runGitTest(t, ctx, "-C", origin, "branch", "develop")
+
+	bareDir := filepath.Join(root, ".bare")
+	runGitTest(t, ctx, "clone", "--bare", origin, bareDir)
+	runGitTest(t, ctx, "--git-dir", bareDir, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+	runGitTest(t, ctx, "--git-dir", bareDir, "fetch", "origin")
+
+	if err := os.WriteFile(filepath.Join(root, ".git"), []byte(gitdirFileContent()), 0o644); err != nil {
+		t.Fatalf("write .git: %v", err)
+	}
+	if err := writeGitsejConfig(root, "main", "main"); err != nil {
+		t.Fatalf("writeGitsejConfig: %v", err)
+	}
+	return canonicalPath(root)
+}
@@ -0,0 +1,88 @@
+package gitsej
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cloneArgs returns the git clone arguments for the clone settings in cfg.
+// git clone --depth implies --single-branch, so full-branch shallow clones
+// ask for --no-single-branch explicitly.
+func cloneArgs(cfg Config) []string {
+	args := make([]string, 0, 4)
+	if cfg.CloneDepth > 0 {
+		args = append(args, "--depth", strconv.Itoa(cfg.CloneDepth))
+	}
+	if cfg.CloneFilter != "" {
+		args = append(args, "--filter="+cfg.CloneFilter)
+	}
+	switch {
+	case cfg.SingleBranch:
+		args = append(args, "--single-branch")
+	case cfg.CloneDepth > 0:
+		args = append(args, "--no-single-branch")
+	}
+	return args
+}
+
+// cloneFetchArgs returns extra git fetch arguments that keep later fetches as
+// shallow as the original clone. Partial clone filters need none: git records
+// them on the origin remote and applies them to every fetch.
+func cloneFetchArgs(cfg Config) []string {
+	if cfg.CloneDepth > 0 {
+		return []string{"--depth", strconv.Itoa(cfg.CloneDepth)}
+	}
+	return nil
+}
+
+// fetchRefspec returns the origin fetch refspec for cfg: every branch, or
+// only main_branch for single-branch roots.
+func fetchRefspec(cfg Config) string {
+	if cfg.SingleBranch {
+		return "+refs/heads/" + cfg.MainBranch + ":refs/remotes/origin/" + cfg.MainBranch
+	}
+	return originFetchRefspec
+}
+
+// fetchOriginBranch fetches branch from origin into its remote-tracking ref
+// and adds it to the fetch refspec, so single-branch roots keep it updated
+// like git remote set-branches --add would. It reports whether origin had
+// the branch.
+func fetchOriginBranch(ctx context.Context, root string, cfg Config, branch string) bool {
+	refspec := "+refs/heads/" + branch + ":refs/remotes/origin/" + branch
+	args := append([]string{"-C", root, "fetch"}, cloneFetchArgs(cfg)...)
+	if err := runGit(ctx, append(args, "origin", refspec)...); err != nil {
+		return false
+	}
+	_ = runGit(ctx, "-C", root, "config", "--add", "remote.origin.fetch", refspec)
+	return true
+}
+
+// partialCloneProblem reports why a clone made with filter is not a partial
+// clone. git falls back to a full clone, with only a warning, when the remote
+// does not allow filters or the URL is a local path; the first leaves
+// partialclonefilter unset, the second leaves no promisor pack behind.
+func partialCloneProblem(ctx context.Context, bareDir, filter string) string {
+	if filter == "" {
+		return ""
+	}
+	promisor, _ := runGitOutput(ctx, "--git-dir", bareDir, "config", "--bool", "remote.origin.promisor")
+	active, _ := runGitOutput(ctx, "--git-dir", bareDir, "config", "remote.origin.partialclonefilter")
+	packs, _ := filepath.Glob(filepath.Join(bareDir, "objects", "pack", "*.promisor"))
+	if strings.TrimSpace(promisor) == "true" && strings.TrimSpace(active) == filter && len(packs) > 0 {
+		return ""
+	}
+	return fmt.Sprintf("clone_filter=%s is not active; origin does not support partial clone (local paths need a file:// URL)", filter)
+}
+
+func normalizeCloneFilter(value string) (string, error) {
+	switch value = strings.TrimSpace(value); value {
+	case "", "blob:none", "tree:0":
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported clone filter %q: expected blob:none or tree:0", value)
+	}
+}
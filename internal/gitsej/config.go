@@ -0,0 +1,555 @@
+package gitsej
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMainWorktree = "main"
+	defaultMainBranch   = "main"
+	defaultCooldown     = 300
+	defaultLockTimeout  = 30
+)
+
+// Config is the typed form of a .gitsej file. Load keeps the original lines so
+// Save can write the values back without dropping comments or unknown keys.
+type Config struct {
+	Label        string
+	MainWorktree string
+	MainBranch   string
+	Cooldown     int
+	AutoUpdate   bool
+	KeepIgnored  []string
+	CloneDepth   int
+	CloneFilter  string
+	SingleBranch bool
+	LockTimeout  int
+	// PostWorktreeAddHook is a shell command run in every new worktree.
+	PostWorktreeAddHook string
+	// SparseProfiles maps sparse.<name> profiles to their directories.
+	SparseProfiles map[string][]string
+
+	lines []configLine
+	saved map[string]string
+}
+
+type configLine struct {
+	raw   string
+	key   string
+	value string
+}
+
+// ConfigError describes a malformed line in a .gitsej file.
+type ConfigError struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+}
+
+// DefaultConfig returns the configuration written for new gitsej repos.
+func DefaultConfig(mainBranch, mainWorktree string) Config {
+	cfg, err := parseConfig("", gitsejConfigContent(mainBranch, mainWorktree))
+	if err != nil {
+		return Config{
+			MainWorktree: mainWorktree,
+			MainBranch:   mainBranch,
+			Cooldown:     defaultCooldown,
+			LockTimeout:  defaultLockTimeout,
+		}
+	}
+	return cfg
+}
+
+// Load reads root/.gitsej into c. Keys missing from the file keep their
+// defaults. The returned error wraps os.ErrNotExist when there is no file.
+func (c *Config) Load(root string) error {
+	path := filepath.Join(root, ".gitsej")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read .gitsej: %w", err)
+	}
+
+	cfg, err := parseConfig(path, string(content))
+	if err != nil {
+		return err
+	}
+	*c = cfg
+	return nil
+}
+
+// Save writes c to root/.gitsej. Lines of the loaded file are kept as they
+// were unless their value changed; known keys that are missing from the file
+// are appended when they differ from their defaults.
+func (c *Config) Save(root string) error {
+	path := filepath.Join(root, ".gitsej")
+	content := c.render()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write .gitsej: %w", err)
+	}
+
+	saved, err := parseConfig(path, content)
+	if err != nil {
+		return err
+	}
+	*c = saved
+	return nil
+}
+
+// Has reports whether key is set in the loaded file.
+func (c *Config) Has(key string) bool {
+	for _, line := range c.lines {
+		if line.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValue reports whether .gitsej sets key to a non-empty value.
+func (c *Config) hasValue(key string) bool {
+	for _, line := range c.lines {
+		if line.key == key && line.value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// strayLines returns a ConfigError for every line of the loaded file that is
+// neither blank, a comment nor a key=value setting.
+func (c *Config) strayLines(path string) []*ConfigError {
+	var errs []*ConfigError
+	for i, line := range c.lines {
+		trimmed := strings.TrimSpace(line.raw)
+		if line.key != "" || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		errs = append(errs, &ConfigError{Path: path, Line: i + 1, Msg: fmt.Sprintf("expected key=value, got %q", trimmed)})
+	}
+	return errs
+}
+
+// MainWorktreePath resolves MainWorktree against root.
+func (c *Config) MainWorktreePath(root string) string {
+	return resolveMainWorktreePath(root, c.MainWorktree)
+}
+
+// resolveMainWorktreePath resolves a main_worktree value: absolute paths are
+// used as is, relative ones are taken relative to root.
+func resolveMainWorktreePath(root, mainWorktree string) string {
+	mainWorktree = strings.TrimSpace(mainWorktree)
+	if mainWorktree == "" {
+		mainWorktree = defaultMainWorktree
+	}
+	if filepath.IsAbs(mainWorktree) {
+		return filepath.Clean(mainWorktree)
+	}
+	return filepath.Join(root, mainWorktree)
+}
+
+// normalizeMainWorktree validates a main_worktree value, defaulting to "main".
+// Relative values must not name the gitsej root itself or its metadata.
+func normalizeMainWorktree(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaultMainWorktree, nil
+	}
+	if filepath.IsAbs(value) {
+		return value, nil
+	}
+
+	cleaned := filepath.Clean(value)
+	first := strings.Split(cleaned, string(os.PathSeparator))[0]
+	switch first {
+	case ".", ".bare", ".git", ".gitsej", hooksDirName:
+		return "", fmt.Errorf("invalid main_worktree %q: must not be the gitsej root or its metadata", value)
+	}
+	return value, nil
+}
+
+// loadConfig loads root/.gitsej, falling back to defaults when the file does
+// not exist.
+func loadConfig(root string) (Config, error) {
+	var cfg Config
+	if err := cfg.Load(root); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			cfg, err = parseConfig("", "")
+			return cfg, err
+		}
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func parseConfig(path, content string) (Config, error) {
+	if path == "" {
+		path = ".gitsej"
+	}
+
+	cfg := Config{
+		MainWorktree: defaultMainWorktree,
+		MainBranch:   defaultMainBranch,
+		Cooldown:     defaultCooldown,
+		LockTimeout:  defaultLockTimeout,
+	}
+
+	content = strings.TrimSuffix(content, "\n")
+	if content != "" {
+		for i, raw := range strings.Split(content, "\n") {
+			line := configLine{raw: raw}
+			trimmed := strings.TrimSpace(raw)
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				key, value, ok := strings.Cut(trimmed, "=")
+				key = strings.TrimSpace(key)
+				// Lines that are not settings are kept as they are, like
+				// comments; doctor reports them.
+				if ok && key != "" {
+					line.key = key
+					line.value = strings.TrimSpace(value)
+					if err := cfg.apply(line.key, line.value); err != nil {
+						return Config{}, &ConfigError{Path: path, Line: i + 1, Msg: err.Error()}
+					}
+				}
+			}
+			cfg.lines = append(cfg.lines, line)
+		}
+	}
+
+	cfg.saved = cfg.values()
+	return cfg, nil
+}
+
+func (c *Config) apply(key, value string) error {
+	switch key {
+	case "label":
+		c.Label = value
+	case "main_worktree":
+		mainWorktree, err := normalizeMainWorktree(value)
+		if err != nil {
+			return err
+		}
+		c.MainWorktree = mainWorktree
+	case "main_branch":
+		if value == "" {
+			value = defaultMainBranch
+		}
+		c.MainBranch = value
+	case "cooldown":
+		cooldown, err := strconv.Atoi(value)
+		if err != nil || cooldown < 0 {
+			return fmt.Errorf("invalid cooldown %q: expected a non-negative number of seconds", value)
+		}
+		c.Cooldown = cooldown
+	case "auto_update":
+		autoUpdate, err := parseConfigBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid auto_update %q: %w", value, err)
+		}
+		c.AutoUpdate = autoUpdate
+	case "keep_ignored":
+		patterns, err := parsePatternList(value)
+		if err != nil {
+			return fmt.Errorf("invalid keep_ignored %q: %w", value, err)
+		}
+		c.KeepIgnored = patterns
+	case "lock_timeout":
+		timeout, err := strconv.Atoi(value)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid lock_timeout %q: expected a non-negative number of seconds", value)
+		}
+		c.LockTimeout = timeout
+	case "clone_depth":
+		depth, err := strconv.Atoi(cmp.Or(value, "0"))
+		if err != nil || depth < 0 {
+			return fmt.Errorf("invalid clone_depth %q: expected a non-negative number of commits", value)
+		}
+		c.CloneDepth = depth
+	case "clone_filter":
+		filter, err := normalizeCloneFilter(value)
+		if err != nil {
+			return fmt.Errorf("invalid clone_filter: %w", err)
+		}
+		c.CloneFilter = filter
+	case "single_branch":
+		singleBranch, err := parseConfigBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid single_branch %q: %w", value, err)
+		}
+		c.SingleBranch = singleBranch
+	case "hook." + postWorktreeAddHook:
+		c.PostWorktreeAddHook = value
+	default:
+		name, ok := sparseProfileName(key)
+		if !ok {
+			return nil
+		}
+		dirs, err := parseSparseDirs(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if len(dirs) == 0 {
+			delete(c.SparseProfiles, name)
+			return nil
+		}
+		if c.SparseProfiles == nil {
+			c.SparseProfiles = make(map[string][]string)
+		}
+		c.SparseProfiles[name] = dirs
+	}
+	return nil
+}
+
+// values returns the typed fields in their .gitsej string form.
+func (c *Config) values() map[string]string {
+	autoUpdate := "0"
+	if c.AutoUpdate {
+		autoUpdate = "1"
+	}
+	singleBranch := "0"
+	if c.SingleBranch {
+		singleBranch = "1"
+	}
+	values := map[string]string{
+		"label":         c.Label,
+		"main_worktree": c.MainWorktree,
+		"main_branch":   c.MainBranch,
+		"cooldown":      strconv.Itoa(c.Cooldown),
+		"auto_update":   autoUpdate,
+		"keep_ignored":  strings.Join(c.KeepIgnored, ","),
+		"lock_timeout":  strconv.Itoa(c.LockTimeout),
+		"clone_depth":   strconv.Itoa(c.CloneDepth),
+		"clone_filter":  c.CloneFilter,
+		"single_branch": singleBranch,
+
+		"hook." + postWorktreeAddHook: c.PostWorktreeAddHook,
+	}
+	for name, dirs := range c.SparseProfiles {
+		values[sparseKeyPrefix+name] = strings.Join(dirs, ",")
+	}
+	return values
+}
+
+// sparseKeys returns the sparse.<name> keys of c in sorted order.
+func (c *Config) sparseKeys() []string {
+	keys := make([]string, 0, len(c.SparseProfiles))
+	for name := range c.SparseProfiles {
+		keys = append(keys, sparseKeyPrefix+name)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (c *Config) render() string {
+	current := c.values()
+	defaults := (&Config{
+		MainWorktree: defaultMainWorktree,
+		MainBranch:   defaultMainBranch,
+		Cooldown:     defaultCooldown,
+		LockTimeout:  defaultLockTimeout,
+	}).values()
+
+	last := make(map[string]int, len(current))
+	for i, line := range c.lines {
+		if _, known := current[line.key]; known {
+			last[line.key] = i
+		}
+	}
+
+	var b strings.Builder
+	for i, line := range c.lines {
+		value, known := current[line.key]
+		if known && last[line.key] == i && value != c.saved[line.key] {
+			fmt.Fprintf(&b, "%s=%s\n", line.key, value)
+			continue
+		}
+		b.WriteString(line.raw)
+		b.WriteString("\n")
+	}
+
+	for _, key := range configSchema {
+		if _, present := last[key.Name]; present {
+			continue
+		}
+		if current[key.Name] == defaults[key.Name] {
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key.Name, current[key.Name])
+	}
+	for _, key := range c.sparseKeys() {
+		if _, present := last[key]; !present {
+			fmt.Fprintf(&b, "%s=%s\n", key, current[key])
+		}
+	}
+	return b.String()
+}
+
+// configSchema lists the known .gitsej keys in file order, with the default
+// value upgrade appends and an optional comment written above it. Optional
+// keys are left out of new files and never appended by upgrade.
+var configSchema = []configKey{
+	{Name: "label"},
+	{Name: "main_worktree", Default: defaultMainWorktree},
+	{Name: "main_branch", Default: defaultMainBranch},
+	{Name: "cooldown", Default: strconv.Itoa(defaultCooldown)},
+	{Name: "auto_update", Default: "0", Comment: "# 0 = never auto-pull, 1 = auto-pull when clean and behind."},
+	{Name: "keep_ignored", Optional: true},
+	{Name: "lock_timeout", Default: strconv.Itoa(defaultLockTimeout), Optional: true},
+	{Name: "clone_depth", Default: "0", Optional: true},
+	{Name: "clone_filter", Optional: true},
+	{Name: "single_branch", Default: "0", Optional: true},
+	{Name: "hook." + postWorktreeAddHook, Optional: true},
+}
+
+type configKey struct {
+	Name     string
+	Default  string
+	Comment  string
+	Optional bool
+}
+
+// ConfigKeys returns the names of the known .gitsej keys.
+func ConfigKeys() []string {
+	keys := make([]string, 0, len(configSchema))
+	for _, key := range configSchema {
+		keys = append(keys, key.Name)
+	}
+	return keys
+}
+
+func isConfigKey(name string) bool {
+	if _, ok := sparseProfileName(name); ok {
+		return true
+	}
+	for _, key := range configSchema {
+		if key.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the effective value of a known key in its .gitsej form.
+func (c *Config) Get(key string) (string, error) {
+	if !isConfigKey(key) {
+		return "", unknownConfigKeyError(key)
+	}
+	return c.values()[key], nil
+}
+
+// Set validates value and assigns it to a known key.
+func (c *Config) Set(key, value string) error {
+	if !isConfigKey(key) {
+		return unknownConfigKeyError(key)
+	}
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("invalid %s: value must be a single line", key)
+	}
+	if err := c.apply(key, value); err != nil {
+		return err
+	}
+	// An empty sparse.<name> deletes the profile; its lines must go too or
+	// the profile comes back the next time the file is loaded.
+	if name, ok := sparseProfileName(key); ok {
+		if _, defined := c.SparseProfiles[name]; !defined {
+			c.removeLines(key)
+			delete(c.saved, key)
+		}
+	}
+	return nil
+}
+
+// Unset removes every line setting key and restores its default.
+func (c *Config) Unset(key string) error {
+	if !isConfigKey(key) {
+		return unknownConfigKeyError(key)
+	}
+	c.removeLines(key)
+
+	defaults := Config{
+		MainWorktree: defaultMainWorktree,
+		MainBranch:   defaultMainBranch,
+		Cooldown:     defaultCooldown,
+		LockTimeout:  defaultLockTimeout,
+	}
+	value := defaults.values()[key]
+	delete(c.saved, key)
+	return c.apply(key, value)
+}
+
+func (c *Config) removeLines(key string) {
+	lines := make([]configLine, 0, len(c.lines))
+	for _, line := range c.lines {
+		if line.key != key {
+			lines = append(lines, line)
+		}
+	}
+	c.lines = lines
+}
+
+type ConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// Entries returns the effective value of every known key, then the sparse
+// profiles, then the unknown keys found in the loaded file, in file order.
+func (c *Config) Entries() []ConfigEntry {
+	values := c.values()
+	entries := make([]ConfigEntry, 0, len(configSchema))
+	for _, key := range configSchema {
+		entries = append(entries, ConfigEntry{Key: key.Name, Value: values[key.Name]})
+	}
+	for _, key := range c.sparseKeys() {
+		entries = append(entries, ConfigEntry{Key: key, Value: values[key]})
+	}
+	for _, line := range c.lines {
+		if line.key != "" && !isConfigKey(line.key) {
+			entries = append(entries, ConfigEntry{Key: line.key, Value: line.value})
+		}
+	}
+	return entries
+}
+
+func unknownConfigKeyError(key string) error {
+	return fmt.Errorf("unknown .gitsej key %q (known keys: %s, %s<name>)", key, strings.Join(ConfigKeys(), ", "), sparseKeyPrefix)
+}
+
+// parsePatternList splits a comma-separated list of glob patterns, dropping
+// empty entries.
+func parsePatternList(value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "", "0", "false", "no", "off":
+		return false, nil
+	default:
+		return false, errors.New("expected 0 or 1")
+	}
+}
@@ -0,0 +1,120 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ConfigOptions struct {
+	Directory string
+	Key       string
+	Value     string
+}
+
+type ConfigResult struct {
+	Root    string
+	Path    string
+	Entries []ConfigEntry
+}
+
+// ListConfig returns the effective .gitsej settings of the gitsej root
+// containing opts.Directory.
+func ListConfig(ctx context.Context, opts ConfigOptions) (ConfigResult, error) {
+	root, cfg, err := loadRootConfig(ctx, opts.Directory)
+	if err != nil {
+		return ConfigResult{}, err
+	}
+	return ConfigResult{
+		Root:    root,
+		Path:    filepath.Join(root, ".gitsej"),
+		Entries: cfg.Entries(),
+	}, nil
+}
+
+// GetConfig returns the effective value of opts.Key.
+func GetConfig(ctx context.Context, opts ConfigOptions) (string, error) {
+	_, cfg, err := loadRootConfig(ctx, opts.Directory)
+	if err != nil {
+		return "", err
+	}
+	return cfg.Get(strings.TrimSpace(opts.Key))
+}
+
+// SetConfig validates opts.Value for opts.Key and writes it to .gitsej,
+// creating the file from defaults when it is missing.
+func SetConfig(ctx context.Context, opts ConfigOptions) (ConfigResult, error) {
+	root, cfg, err := loadRootConfig(ctx, opts.Directory)
+	if err != nil {
+		return ConfigResult{}, err
+	}
+
+	key := strings.TrimSpace(opts.Key)
+	value := strings.TrimSpace(opts.Value)
+	if err := cfg.Set(key, value); err != nil {
+		return ConfigResult{}, err
+	}
+	// Report the value as stored, e.g. trimmed globs or a default branch.
+	if value, err = cfg.Get(key); err != nil {
+		return ConfigResult{}, err
+	}
+	if key == "main_branch" {
+		if !gitRefExists(ctx, root, "refs/heads/"+value) && !gitRefExists(ctx, root, "refs/remotes/origin/"+value) {
+			return ConfigResult{}, fmt.Errorf("invalid main_branch: branch %s does not exist locally or on origin", value)
+		}
+	}
+
+	if err := cfg.Save(root); err != nil {
+		return ConfigResult{}, err
+	}
+	return ConfigResult{
+		Root:    root,
+		Path:    filepath.Join(root, ".gitsej"),
+		Entries: []ConfigEntry{{Key: key, Value: value}},
+	}, nil
+}
+
+// UnsetConfig removes opts.Key from .gitsej so its default applies again.
+func UnsetConfig(ctx context.Context, opts ConfigOptions) (ConfigResult, error) {
+	root, cfg, err := loadRootConfig(ctx, opts.Directory)
+	if err != nil {
+		return ConfigResult{}, err
+	}
+
+	key := strings.TrimSpace(opts.Key)
+	if err := cfg.Unset(key); err != nil {
+		return ConfigResult{}, err
+	}
+	if err := cfg.Save(root); err != nil {
+		return ConfigResult{}, err
+	}
+
+	value, err := cfg.Get(key)
+	if err != nil {
+		return ConfigResult{}, err
+	}
+	return ConfigResult{
+		Root:    root,
+		Path:    filepath.Join(root, ".gitsej"),
+		Entries: []ConfigEntry{{Key: key, Value: value}},
+	}, nil
+}
+
+func loadRootConfig(ctx context.Context, dir string) (string, Config, error) {
+	root, err := ResolveRoot(ctx, dir)
+	if err != nil {
+		return "", Config{}, err
+	}
+
+	var cfg Config
+	if err := cfg.Load(root); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", Config{}, err
+		}
+		cfg = DefaultConfig(defaultMainBranch, defaultMainWorktree)
+	}
+	return root, cfg, nil
+}
@@ -0,0 +1,124 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetConfigValidatesAndKeepsComments(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "cooldown", Value: "abc"}); err == nil {
+		t.Fatalf("expected error for non-numeric cooldown")
+	}
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "auto_update", Value: "sometimes"}); err == nil {
+		t.Fatalf("expected error for non-boolean auto_update")
+	}
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "main_branch", Value: "missing"}); err == nil {
+		t.Fatalf("expected error for unknown branch")
+	}
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "bogus", Value: "1"}); err == nil {
+		t.Fatalf("expected error for unknown key")
+	}
+
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "main_branch", Value: "develop"}); err != nil {
+		t.Fatalf("SetConfig(main_branch): %v", err)
+	}
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "auto_update", Value: "true"}); err != nil {
+		t.Fatalf("SetConfig(auto_update): %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "main_branch=develop\n") || !strings.Contains(content, "auto_update=1\n") {
+		t.Fatalf("expected updated values, got:\n%s", content)
+	}
+	if !strings.Contains(content, "# 0 = never auto-pull, 1 = auto-pull when clean and behind.\n") {
+		t.Fatalf("expected comments preserved, got:\n%s", content)
+	}
+
+	value, err := GetConfig(ctx, ConfigOptions{Directory: root, Key: "main_branch"})
+	if err != nil {
+		t.Fatalf("GetConfig: %v", err)
+	}
+	if value != "develop" {
+		t.Fatalf("GetConfig(main_branch) = %q, want develop", value)
+	}
+}
+
+func TestUnsetConfigRestoresDefault(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if err := os.WriteFile(filepath.Join(root, ".gitsej"), []byte("# mine\ncooldown=10\ncustom=1\n"), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	result, err := UnsetConfig(ctx, ConfigOptions{Directory: root, Key: "cooldown"})
+	if err != nil {
+		t.Fatalf("UnsetConfig: %v", err)
+	}
+	if got := result.Entries[0].Value; got != "300" {
+		t.Fatalf("default cooldown = %q, want 300", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if got, want := string(data), "# mine\ncustom=1\n"; got != want {
+		t.Fatalf(".gitsej = %q, want %q", got, want)
+	}
+
+	list, err := ListConfig(ctx, ConfigOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("ListConfig: %v", err)
+	}
+	last := list.Entries[len(list.Entries)-1]
+	if last.Key != "custom" || last.Value != "1" {
+		t.Fatalf("expected unknown key listed last, got %+v", list.Entries)
+	}
+}
+
+func TestSetConfigEmptySparseProfileRemovesIt(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	result, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "sparse.api", Value: " services/api/ , libs "})
+	if err != nil {
+		t.Fatalf("SetConfig(sparse.api): %v", err)
+	}
+	if got, want := result.Entries[0].Value, "services/api,libs"; got != want {
+		t.Fatalf("SetConfig reported %q, want %q", got, want)
+	}
+
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "sparse.api", Value: ""}); err != nil {
+		t.Fatalf("SetConfig(sparse.api=): %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if strings.Contains(string(data), "sparse.api") {
+		t.Fatalf("expected sparse.api to be removed, got:\n%s", data)
+	}
+	cfg, err := loadConfig(root)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if _, ok := cfg.SparseProfiles["api"]; ok {
+		t.Fatalf("sparse profile came back on reload: %v", cfg.SparseProfiles)
+	}
+}
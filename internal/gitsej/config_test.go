@@ -0,0 +1,229 @@
+package gitsej
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigLoadParsesTypedValues(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := `# team repo
+label=api
+main_worktree=trunk
+main_branch=develop
+cooldown=42
+auto_update=1
+keep_ignored=.env, node_modules,,*.local
+custom_key=keepme
+`
+	if err := os.WriteFile(filepath.Join(dir, ".gitsej"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(dir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Label != "api" || cfg.MainWorktree != "trunk" || cfg.MainBranch != "develop" {
+		t.Fatalf("unexpected string values: %+v", cfg)
+	}
+	if cfg.Cooldown != 42 {
+		t.Fatalf("cfg.Cooldown = %d, want 42", cfg.Cooldown)
+	}
+	if !cfg.AutoUpdate {
+		t.Fatalf("expected auto_update to be true")
+	}
+	if got, want := strings.Join(cfg.KeepIgnored, "|"), ".env|node_modules|*.local"; got != want {
+		t.Fatalf("cfg.KeepIgnored = %q, want %q", got, want)
+	}
+	if got, want := cfg.MainWorktreePath(dir), filepath.Join(dir, "trunk"); got != want {
+		t.Fatalf("MainWorktreePath = %q, want %q", got, want)
+	}
+}
+
+func TestConfigLoadDefaultsMissingKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".gitsej"), []byte("label=x\n"), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(dir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MainWorktree != "main" || cfg.MainBranch != "main" || cfg.Cooldown != 300 || cfg.AutoUpdate {
+		t.Fatalf("expected defaults for missing keys, got %+v", cfg)
+	}
+	if cfg.Has("cooldown") {
+		t.Fatalf("did not expect cooldown to be reported as set")
+	}
+}
+
+func TestConfigLoadReportsLineNumbers(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		content string
+		line    int
+	}{
+		{name: "bad cooldown", content: "# c\nlabel=x\ncooldown=abc\n", line: 3},
+		{name: "negative cooldown", content: "cooldown=-1\n", line: 1},
+		{name: "bad auto_update", content: "label=\n\nauto_update=maybe\n", line: 3},
+		{name: "bad keep_ignored", content: "keep_ignored=.env,[\n", line: 1},
+		{name: "negative clone_depth", content: "clone_depth=-3\n", line: 1},
+		{name: "negative lock_timeout", content: "lock_timeout=-1\n", line: 1},
+		{name: "bad clone_filter", content: "label=\nclone_filter=blob:limit=1k\n", line: 2},
+		{name: "bad single_branch", content: "single_branch=sometimes\n", line: 1},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, ".gitsej"), []byte(tc.content), 0o644); err != nil {
+				t.Fatalf("write .gitsej: %v", err)
+			}
+
+			var cfg Config
+			err := cfg.Load(dir)
+			var cfgErr *ConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("expected ConfigError, got %T (%v)", err, err)
+			}
+			if cfgErr.Line != tc.line {
+				t.Fatalf("error line = %d, want %d (%v)", cfgErr.Line, tc.line, err)
+			}
+		})
+	}
+}
+
+func TestConfigKeepsLinesWithoutSeparator(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := "label=x\nnot a setting\ncooldown=60\n"
+	configPath := filepath.Join(dir, ".gitsej")
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(dir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Label != "x" || cfg.Cooldown != 60 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	stray := cfg.strayLines(configPath)
+	if len(stray) != 1 || stray[0].Line != 2 {
+		t.Fatalf("strayLines = %v, want line 2", stray)
+	}
+
+	cfg.Cooldown = 120
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if want := "label=x\nnot a setting\ncooldown=120\n"; string(data) != want {
+		t.Fatalf("unexpected .gitsej:\n%s", string(data))
+	}
+}
+
+func TestConfigLoadMissingFile(t *testing.T) {
+	t.Parallel()
+
+	var cfg Config
+	if err := cfg.Load(t.TempDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestConfigSavePreservesCommentsAndUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := `# team repo
+label = api
+# fetch every minute
+cooldown=60
+custom_key=keepme
+`
+	configPath := filepath.Join(dir, ".gitsej")
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(dir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save(unchanged): %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected unchanged round-trip; got:\n%s", string(data))
+	}
+
+	cfg.Cooldown = 120
+	cfg.AutoUpdate = true
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err = os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+
+	want := `# team repo
+label = api
+# fetch every minute
+cooldown=120
+custom_key=keepme
+auto_update=1
+`
+	if string(data) != want {
+		t.Fatalf("unexpected saved config:\n%s\nwant:\n%s", string(data), want)
+	}
+}
+
+func TestDefaultConfigMatchesTemplate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := DefaultConfig("trunk", "main")
+	if cfg.MainBranch != "trunk" {
+		t.Fatalf("cfg.MainBranch = %q, want trunk", cfg.MainBranch)
+	}
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if string(data) != gitsejConfigContent("trunk", "main") {
+		t.Fatalf("expected default template, got:\n%s", string(data))
+	}
+	if !strings.Contains(string(data), "# 0 = never auto-pull") {
+		t.Fatalf("expected template comments preserved, got:\n%s", string(data))
+	}
+}
@@ -4,19 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// createCloneName is the directory in .bare that Create clones into.
+const createCloneName = "gitsej-clone"
+
 type CreateOptions struct {
-	RepoURL      string
-	Directory    string
-	MainWorktree bool
-	MainBranch   string
+	RepoURL         string
+	Directory       string
+	MainWorktree    bool
+	MainWorktreeDir string
+	MainBranch      string
+	// Depth, Filter and SingleBranch shape the clone and are recorded in
+	// .gitsej so later fetches and worktrees follow them.
+	Depth        int
+	Filter       string
+	SingleBranch bool
+	// Sparse is a <name>=<dir>,<dir> sparse-checkout profile, recorded in
+	// .gitsej and applied to the main worktree.
+	Sparse string
+	// PostWorktreeAddHook is recorded as hook.post_worktree_add, and the
+	// post_worktree_add hooks run in the main worktree with their output
+	// written to HookOutput.
+	PostWorktreeAddHook string
+	HookOutput          io.Writer
 }
 
 func Create(ctx context.Context, opts CreateOptions) (string, error) {
@@ -34,9 +53,21 @@ func Create(ctx context.Context, opts CreateOptions) (string, error) {
 		}
 	}
 
-	mainBranch := strings.TrimSpace(opts.MainBranch)
-	if mainBranch == "" {
-		mainBranch = "main"
+	mainWorktreeDir, err := normalizeMainWorktree(opts.MainWorktreeDir)
+	if err != nil {
+		return "", err
+	}
+	if opts.Depth < 0 {
+		return "", fmt.Errorf("invalid depth %d: expected a non-negative number of commits", opts.Depth)
+	}
+	filter, err := normalizeCloneFilter(opts.Filter)
+	if err != nil {
+		return "", err
+	}
+	// A new root has no profiles yet, so only inline definitions resolve.
+	sparse, err := resolveSparseProfile(Config{}, opts.Sparse)
+	if err != nil {
+		return "", err
 	}
 
 	if _, err := os.Stat(targetDir); err == nil {
@@ -45,7 +76,11 @@ func Create(ctx context.Context, opts CreateOptions) (string, error) {
 		return "", fmt.Errorf("check directory %s: %w", targetDir, err)
 	}
 
-	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0o755); err != nil {
+		return "", fmt.Errorf("create directory %s: %w", filepath.Dir(targetDir), err)
+	}
+	// Mkdir fails if a concurrent create got here first.
+	if err := os.Mkdir(targetDir, 0o755); err != nil {
 		return "", fmt.Errorf("create directory %s: %w", targetDir, err)
 	}
 
@@ -56,29 +91,102 @@ func Create(ctx context.Context, opts CreateOptions) (string, error) {
 		}
 	}()
 
+	mainBranch := strings.TrimSpace(opts.MainBranch)
+	clone := Config{CloneDepth: opts.Depth, CloneFilter: filter, SingleBranch: opts.SingleBranch}
+
+	// The lock is taken before cloning, so git clones into a directory next
+	// to it and the clone is moved into .bare afterwards.
 	bareDir := filepath.Join(targetDir, ".bare")
-	if err := runGit(ctx, "clone", "--bare", repoURL, bareDir); err != nil {
+	if err := os.Mkdir(bareDir, 0o755); err != nil {
+		return "", fmt.Errorf("create directory %s: %w", bareDir, err)
+	}
+	lock, err := lockGitDir(ctx, bareDir, "create", defaultLockTimeout*time.Second)
+	if err != nil {
 		return "", err
 	}
+	defer lock.Unlock()
+
+	cloneDir := filepath.Join(bareDir, createCloneName)
+	cloneCmd := append([]string{"clone", "--bare"}, cloneArgs(clone)...)
+	if opts.SingleBranch && mainBranch != "" {
+		cloneCmd = append(cloneCmd, "--branch", mainBranch)
+	}
+	if err := runGit(ctx, append(cloneCmd, repoURL, cloneDir)...); err != nil {
+		return "", err
+	}
+	if err := moveDirEntries(cloneDir, bareDir); err != nil {
+		return "", err
+	}
+	if problem := partialCloneProblem(ctx, bareDir, filter); problem != "" {
+		return "", errors.New(problem)
+	}
+
+	if mainBranch == "" {
+		mainBranch = detectRemoteDefaultBranch(ctx, bareDir)
+	}
+	cfg := DefaultConfig(mainBranch, mainWorktreeDir)
+	cfg.CloneDepth = clone.CloneDepth
+	cfg.CloneFilter = clone.CloneFilter
+	cfg.SingleBranch = clone.SingleBranch
+	cfg.PostWorktreeAddHook = strings.TrimSpace(opts.PostWorktreeAddHook)
+	if sparse.Define {
+		cfg.SparseProfiles = map[string][]string{sparse.Name: sparse.Dirs}
+	}
 
 	if err := os.WriteFile(filepath.Join(targetDir, ".git"), []byte(gitdirFileContent()), 0o644); err != nil {
 		return "", fmt.Errorf("write .git: %w", err)
 	}
 
-	if err := writeGitsejConfig(targetDir, mainBranch); err != nil {
+	if err := cfg.Save(targetDir); err != nil {
+		return "", err
+	}
+
+	if err := configureFetchRefspec(ctx, bareDir); err != nil {
 		return "", err
 	}
 
 	if opts.MainWorktree {
-		if err := createMainWorktree(ctx, targetDir, mainBranch); err != nil {
+		// git runs inside targetDir, so a relative main worktree path must
+		// not be resolved against a relative targetDir.
+		absTarget, err := filepath.Abs(targetDir)
+		if err != nil {
+			return "", fmt.Errorf("resolve path %s: %w", targetDir, err)
+		}
+		mainWorktreePath := resolveMainWorktreePath(absTarget, mainWorktreeDir)
+		if err := createMainWorktree(ctx, targetDir, mainWorktreePath, mainBranch, sparse); err != nil {
 			return "", err
 		}
+
+		// The repo is complete; a failing hook leaves it in place, and hooks
+		// may run gitsej commands against it.
+		removeOnError = false
+		lock.Unlock()
+		if err := runPostWorktreeAddHooks(ctx, absTarget, cfg, mainWorktreePath, mainBranch, opts.HookOutput); err != nil {
+			return targetDir, err
+		}
 	}
 
 	removeOnError = false
 	return targetDir, nil
 }
 
+// moveDirEntries moves everything in from into to, then removes from.
+func moveDirEntries(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return fmt.Errorf("read directory %s: %w", from, err)
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())); err != nil {
+			return fmt.Errorf("move %s into %s: %w", entry.Name(), to, err)
+		}
+	}
+	if err := os.Remove(from); err != nil {
+		return fmt.Errorf("remove %s: %w", from, err)
+	}
+	return nil
+}
+
 func inferDirectoryName(repoURL string) (string, error) {
 	trimmed := strings.TrimSpace(repoURL)
 	trimmed = strings.TrimSuffix(trimmed, "/")
@@ -112,37 +220,107 @@ func inferDirectoryName(repoURL string) (string, error) {
 	return dir, nil
 }
 
-func writeGitsejConfig(targetDir, mainBranch string) error {
-	content := gitsejConfigContent(mainBranch)
-	if err := os.WriteFile(filepath.Join(targetDir, ".gitsej"), []byte(content), 0o644); err != nil {
-		return fmt.Errorf("write .gitsej: %w", err)
-	}
-	return nil
+func writeGitsejConfig(targetDir, mainBranch, mainWorktree string) error {
+	cfg := DefaultConfig(mainBranch, mainWorktree)
+	return cfg.Save(targetDir)
 }
 
-func gitsejConfigContent(mainBranch string) string {
+func gitsejConfigContent(mainBranch, mainWorktree string) string {
 	return fmt.Sprintf(`# gitsej repo configuration
 # Optional label shown in tmux status; defaults to directory name.
 label=
-main_worktree=main
+main_worktree=%s
 main_branch=%s
 cooldown=300
 # 0 = never auto-pull, 1 = auto-pull when clean and behind.
 auto_update=0
-`, mainBranch)
+`, mainWorktree, mainBranch)
 }
 
 func gitdirFileContent() string {
 	return "gitdir: ./.bare\n"
 }
 
-func createMainWorktree(ctx context.Context, targetDir, mainBranch string) error {
-	mainWorktreePath := filepath.Join(targetDir, "main")
+const originFetchRefspec = "+refs/heads/*:refs/remotes/origin/*"
+
+// configureFetchRefspec sets the origin fetch refspec that git clone --bare
+// leaves out and fetches once so origin/* remote-tracking branches exist.
+// Single-branch and shallow roots keep their shape, per .gitsej.
+func configureFetchRefspec(ctx context.Context, bareDir string) error {
+	if err := writeFetchRefspec(ctx, bareDir); err != nil {
+		return err
+	}
+	return fetchOrigin(ctx, bareDir)
+}
+
+// writeFetchRefspec sets the origin fetch refspec without fetching, for
+// commands that must work offline.
+func writeFetchRefspec(ctx context.Context, bareDir string) error {
+	cfg, err := loadConfig(filepath.Dir(bareDir))
+	if err != nil {
+		cfg = Config{}
+	}
+	return runGit(ctx, "--git-dir", bareDir, "config", "remote.origin.fetch", fetchRefspec(cfg))
+}
+
+func fetchOrigin(ctx context.Context, bareDir string) error {
+	cfg, err := loadConfig(filepath.Dir(bareDir))
+	if err != nil {
+		cfg = Config{}
+	}
+	args := append([]string{"--git-dir", bareDir, "fetch", "--prune"}, cloneFetchArgs(cfg)...)
+	if err := runGit(ctx, append(args, "origin")...); err != nil {
+		return fmt.Errorf("fetch origin: %w", err)
+	}
+	return nil
+}
+
+// hasOriginFetchRefspec reports whether bareDir has an origin remote and
+// whether that remote has any fetch refspec configured.
+func hasOriginFetchRefspec(ctx context.Context, bareDir string) (bool, bool) {
+	if _, err := runGitOutput(ctx, "--git-dir", bareDir, "config", "--get", "remote.origin.url"); err != nil {
+		return false, false
+	}
+	out, err := runGitOutput(ctx, "--git-dir", bareDir, "config", "--get-all", "remote.origin.fetch")
+	return true, err == nil && strings.TrimSpace(out) != ""
+}
+
+// detectRemoteDefaultBranch returns the default branch of origin for a bare
+// clone: the clone's HEAD (which mirrors the remote HEAD), then the remote's
+// advertised HEAD, falling back to main.
+func detectRemoteDefaultBranch(ctx context.Context, bareDir string) string {
+	head, err := runGitOutput(ctx, "--git-dir", bareDir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err == nil {
+		if head = strings.TrimSpace(head); head != "" {
+			return head
+		}
+	}
+
+	out, err := runGitOutput(ctx, "--git-dir", bareDir, "ls-remote", "--symref", "origin", "HEAD")
+	if err == nil {
+		for _, line := range strings.Split(out, "\n") {
+			ref, target, ok := strings.Cut(strings.TrimSpace(line), "\t")
+			if !ok || target != "HEAD" || !strings.HasPrefix(ref, "ref: refs/heads/") {
+				continue
+			}
+			if branch := strings.TrimPrefix(ref, "ref: refs/heads/"); branch != "" {
+				return branch
+			}
+		}
+	}
+
+	return "main"
+}
+
+func createMainWorktree(ctx context.Context, targetDir, mainWorktreePath, mainBranch string, sparse SparseProfile) error {
 	originRef := "origin/" + mainBranch
 
-	if err := runGit(ctx, "-C", targetDir, "worktree", "add", "-B", mainBranch, mainWorktreePath, originRef); err != nil {
+	if err := runGit(ctx, worktreeAddArgs([]string{"-C", targetDir}, sparse, "-B", mainBranch, mainWorktreePath, originRef)...); err != nil {
 		return fmt.Errorf("create main worktree from %s: %w", originRef, err)
 	}
+	if err := applySparseCheckout(ctx, mainWorktreePath, sparse); err != nil {
+		return err
+	}
 
 	_ = runGit(
 		ctx,
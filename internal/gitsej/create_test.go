@@ -1,6 +1,8 @@
 package gitsej
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -70,7 +72,7 @@ func TestWriteGitsejConfig(t *testing.T) {
 	t.Parallel()
 
 	tmpDir := t.TempDir()
-	if err := writeGitsejConfig(tmpDir, "main"); err != nil {
+	if err := writeGitsejConfig(tmpDir, "main", "main"); err != nil {
 		t.Fatalf("writeGitsejConfig: %v", err)
 	}
 
@@ -93,3 +95,179 @@ func TestWriteGitsejConfig(t *testing.T) {
 		t.Fatalf("expected auto_update in config, got:\n%s", content)
 	}
 }
+
+func TestCreateDetectsRemoteDefaultBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "develop", origin)
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "init")
+
+	detected := filepath.Join(base, "detected")
+	if _, err := Create(ctx, CreateOptions{RepoURL: origin, Directory: detected}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	var cfg Config
+	if err := cfg.Load(detected); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MainBranch != "develop" {
+		t.Fatalf("main_branch = %q, want develop", cfg.MainBranch)
+	}
+
+	explicit := filepath.Join(base, "explicit")
+	if _, err := Create(ctx, CreateOptions{RepoURL: origin, Directory: explicit, MainBranch: "release"}); err != nil {
+		t.Fatalf("Create(explicit): %v", err)
+	}
+	if err := cfg.Load(explicit); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MainBranch != "release" {
+		t.Fatalf("main_branch = %q, want release", cfg.MainBranch)
+	}
+}
+
+func TestCreateConfiguresFetchRefspecAndMainWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "init")
+
+	repoDir := filepath.Join(base, "repo")
+	if _, err := Create(ctx, CreateOptions{RepoURL: origin, Directory: repoDir, MainWorktree: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	bareDir := filepath.Join(repoDir, ".bare")
+	if _, err := os.Stat(filepath.Join(bareDir, createCloneName)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the clone to be moved into .bare, stat err=%v", err)
+	}
+	refspec, err := runGitTestOutput(ctx, "--git-dir", bareDir, "config", "--get", "remote.origin.fetch")
+	if err != nil {
+		t.Fatalf("read fetch refspec: %v", err)
+	}
+	if got := strings.TrimSpace(refspec); got != originFetchRefspec {
+		t.Fatalf("remote.origin.fetch = %q, want %q", got, originFetchRefspec)
+	}
+
+	mainWorktree := filepath.Join(repoDir, "main")
+	upstream, err := runGitTestOutput(ctx, "-C", mainWorktree, "rev-parse", "--abbrev-ref", "main@{upstream}")
+	if err != nil {
+		t.Fatalf("main upstream: %v", err)
+	}
+	if strings.TrimSpace(upstream) != "origin/main" {
+		t.Fatalf("main upstream = %q, want origin/main", strings.TrimSpace(upstream))
+	}
+
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "next")
+	runGitTest(t, ctx, "-C", mainWorktree, "fetch")
+	behind, err := runGitTestOutput(ctx, "-C", mainWorktree, "rev-list", "--count", "main..origin/main")
+	if err != nil {
+		t.Fatalf("rev-list: %v", err)
+	}
+	if strings.TrimSpace(behind) != "1" {
+		t.Fatalf("expected origin/main to advance after fetch, behind=%q", strings.TrimSpace(behind))
+	}
+}
+
+func TestCreateShallowSingleBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "one")
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "two")
+	runGitTest(t, ctx, "-C", origin, "branch", "develop")
+
+	repoDir := filepath.Join(base, "repo")
+	if _, err := Create(ctx, CreateOptions{
+		RepoURL:      "file://" + origin,
+		Directory:    repoDir,
+		MainWorktree: true,
+		Depth:        1,
+		SingleBranch: true,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(repoDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CloneDepth != 1 || !cfg.SingleBranch || cfg.MainBranch != "main" {
+		t.Fatalf("config = depth %d single_branch %v main_branch %q", cfg.CloneDepth, cfg.SingleBranch, cfg.MainBranch)
+	}
+
+	bareDir := filepath.Join(repoDir, ".bare")
+	if _, err := os.Stat(filepath.Join(bareDir, "shallow")); err != nil {
+		t.Fatalf("expected a shallow clone: %v", err)
+	}
+	count, err := runGitTestOutput(ctx, "--git-dir", bareDir, "rev-list", "--count", "origin/main")
+	if err != nil {
+		t.Fatalf("rev-list: %v", err)
+	}
+	if strings.TrimSpace(count) != "1" {
+		t.Fatalf("origin/main has %s commits, want 1", strings.TrimSpace(count))
+	}
+	if gitRefExists(ctx, repoDir, "refs/remotes/origin/develop") {
+		t.Fatal("single-branch clone fetched origin/develop")
+	}
+
+	result, err := AddWorktree(ctx, AddWorktreeOptions{Directory: repoDir, Branch: "develop"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	if result.StartPoint != "origin/develop" || result.Upstream != "origin/develop" {
+		t.Fatalf("start point %q upstream %q, want origin/develop", result.StartPoint, result.Upstream)
+	}
+}
+
+func TestCreatePartialClone(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	runGitTest(t, ctx, "-C", origin, "config", "uploadpack.allowfilter", "true")
+	writeTestFile(t, filepath.Join(origin, "README.md"), "hello\n")
+	runGitTest(t, ctx, "-C", origin, "add", "README.md")
+	runGitTest(t, ctx, "-C", origin, "commit", "-m", "init")
+
+	repoDir := filepath.Join(base, "repo")
+	if _, err := Create(ctx, CreateOptions{RepoURL: "file://" + origin, Directory: repoDir, Filter: "blob:none"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	filter, err := runGitTestOutput(ctx, "--git-dir", filepath.Join(repoDir, ".bare"), "config", "remote.origin.partialclonefilter")
+	if err != nil || strings.TrimSpace(filter) != "blob:none" {
+		t.Fatalf("partialclonefilter = %q, %v", strings.TrimSpace(filter), err)
+	}
+	doctor, err := Doctor(ctx, DoctorOptions{Directory: repoDir})
+	if err != nil {
+		t.Fatalf("Doctor: %v", err)
+	}
+	if failed := doctor.Failed(); len(failed) != 0 {
+		t.Fatalf("doctor failed: %+v", failed)
+	}
+
+	// Local paths clone without filters, which Create refuses.
+	localDir := filepath.Join(base, "local")
+	_, err = Create(ctx, CreateOptions{RepoURL: origin, Directory: localDir, Filter: "blob:none"})
+	if err == nil || !strings.Contains(err.Error(), "partial clone") {
+		t.Fatalf("Create(local) error = %v, want partial clone error", err)
+	}
+	if _, err := os.Stat(localDir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %s to be removed, stat err = %v", localDir, err)
+	}
+
+	if _, err := Create(ctx, CreateOptions{RepoURL: origin, Directory: filepath.Join(base, "bad"), Filter: "blob:limit=1k"}); err == nil {
+		t.Fatal("expected an unsupported filter error")
+	}
+}
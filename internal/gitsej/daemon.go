@@ -0,0 +1,231 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDaemonInterval = 30 * time.Second
+	daemonPIDName         = "daemon.pid"
+)
+
+// DefaultRegistryPath returns the file listing the roots gitsej daemon
+// watches, one per line.
+func DefaultRegistryPath() string {
+	if configHome := strings.TrimSpace(os.Getenv("XDG_CONFIG_HOME")); configHome != "" {
+		return filepath.Join(configHome, "gitsej", "roots")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "gitsej", "roots")
+	}
+	return filepath.Join(home, ".config", "gitsej", "roots")
+}
+
+// RegisteredRoots reads the registry at path. A missing registry has no roots.
+func RegisteredRoots(path string) ([]string, error) {
+	if strings.TrimSpace(path) == "" {
+		path = DefaultRegistryPath()
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read registry: %w", err)
+	}
+
+	var roots []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || slices.Contains(roots, line) {
+			continue
+		}
+		roots = append(roots, line)
+	}
+	return roots, nil
+}
+
+// RegisterRoot adds the gitsej root containing dir to the registry at path.
+// It returns the root and whether it was newly added.
+func RegisterRoot(ctx context.Context, path, dir string) (string, bool, error) {
+	root, err := ResolveRoot(ctx, dir)
+	if err != nil {
+		return "", false, err
+	}
+	roots, err := RegisteredRoots(path)
+	if err != nil {
+		return "", false, err
+	}
+	if slices.Contains(roots, root) {
+		return root, false, nil
+	}
+	return root, true, writeRegistry(path, append(roots, root))
+}
+
+// UnregisterRoot removes the root containing dir, or dir itself when it is no
+// longer a gitsej repo, from the registry at path. It returns the root and
+// whether it was registered.
+func UnregisterRoot(ctx context.Context, path, dir string) (string, bool, error) {
+	root, err := ResolveRoot(ctx, dir)
+	if err != nil {
+		if root, err = filepath.Abs(strings.TrimSpace(dir)); err != nil {
+			return "", false, fmt.Errorf("resolve path %s: %w", dir, err)
+		}
+	}
+	roots, err := RegisteredRoots(path)
+	if err != nil {
+		return "", false, err
+	}
+	idx := slices.Index(roots, root)
+	if idx < 0 {
+		return root, false, nil
+	}
+	return root, true, writeRegistry(path, slices.Delete(roots, idx, idx+1))
+}
+
+func writeRegistry(path string, roots []string) error {
+	if strings.TrimSpace(path) == "" {
+		path = DefaultRegistryPath()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create registry directory: %w", err)
+	}
+	content := ""
+	if len(roots) > 0 {
+		content = strings.Join(roots, "\n") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write registry: %w", err)
+	}
+	return nil
+}
+
+type DaemonOptions struct {
+	RegistryPath      string
+	CacheDir          string
+	Interval          time.Duration
+	DefaultCooldown   int
+	DefaultAutoUpdate bool
+	// DefaultMainBranch and DefaultMainWorktree are passed on to MainStatus.
+	DefaultMainBranch   string
+	DefaultMainWorktree string
+	// Log receives a line per failing root, and with Verbose per refreshed
+	// root too; nil discards it.
+	Log     io.Writer
+	Verbose bool
+}
+
+// RunDaemon refreshes the main status of every registered root until ctx is
+// done, checking every opts.Interval. Each root is fetched on its own
+// cooldown, auto_update pulls happen as in MainStatus, and the results land in
+// the status cache, which gitsej status then reads without fetching. The
+// registry is reread on every pass, so roots can be added while it runs.
+func RunDaemon(ctx context.Context, opts DaemonOptions) error {
+	if strings.TrimSpace(opts.CacheDir) == "" {
+		opts.CacheDir = DefaultStatusCacheDir()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultDaemonInterval
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+
+	// The pid file is locked for as long as the daemon runs, so a second
+	// daemon cannot start in between a check and a write, and a stale pid
+	// left by a crash never counts as running.
+	if err := os.MkdirAll(opts.CacheDir, 0o755); err != nil {
+		return fmt.Errorf("create status cache directory: %w", err)
+	}
+	lock, err := lockFile(ctx, filepath.Join(opts.CacheDir, daemonPIDName), "daemon", 0)
+	var busy *RootBusyError
+	if errors.As(err, &busy) {
+		if busy.PID == 0 {
+			return errors.New("gitsej daemon is already running")
+		}
+		return fmt.Errorf("gitsej daemon is already running (pid %d)", busy.PID)
+	}
+	if err != nil {
+		return fmt.Errorf("lock daemon pid file: %w", err)
+	}
+	defer lock.Unlock()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := refreshRegisteredRoots(ctx, opts); err != nil {
+			_, _ = fmt.Fprintf(opts.Log, "%v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshRegisteredRoots runs one daemon pass over the registry.
+func refreshRegisteredRoots(ctx context.Context, opts DaemonOptions) error {
+	roots, err := RegisteredRoots(opts.RegistryPath)
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !IsRoot(root, false) {
+			_, _ = fmt.Fprintf(opts.Log, "%s: not a gitsej repo; skipped\n", root)
+			continue
+		}
+		result, err := MainStatus(ctx, MainStatusOptions{
+			Root:              root,
+			CacheDir:          opts.CacheDir,
+			DefaultCooldown:   opts.DefaultCooldown,
+			DefaultAutoUpdate: opts.DefaultAutoUpdate,
+
+			DefaultMainBranch:   opts.DefaultMainBranch,
+			DefaultMainWorktree: opts.DefaultMainWorktree,
+		})
+		switch {
+		case err != nil:
+			_, _ = fmt.Fprintf(opts.Log, "%s: %v\n", root, err)
+		case result.Refreshed && opts.Verbose:
+			_, _ = fmt.Fprintf(opts.Log, "%s: %s behind=%d dirty=%t\n", root, result.MainBranch, result.Behind, result.Dirty)
+		}
+	}
+	return nil
+}
+
+// DaemonRunning reports whether a gitsej daemon writing to cacheDir is alive,
+// and its pid. A daemon holds the lock on its pid file while it runs.
+func DaemonRunning(cacheDir string) (int, bool) {
+	if strings.TrimSpace(cacheDir) == "" {
+		cacheDir = DefaultStatusCacheDir()
+	}
+	path := filepath.Join(cacheDir, daemonPIDName)
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	locked, err := tryLockFile(file)
+	if err != nil {
+		return 0, false
+	}
+	if locked {
+		_ = unlockFile(file)
+		return 0, false
+	}
+	return readLockHolder(path).PID, true
+}
@@ -0,0 +1,155 @@
+package gitsej
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterRoot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	registry := filepath.Join(t.TempDir(), "gitsej", "roots")
+
+	if roots, err := RegisteredRoots(registry); err != nil || len(roots) != 0 {
+		t.Fatalf("RegisteredRoots(missing) = %v, %v", roots, err)
+	}
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"}); err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	registered, added, err := RegisterRoot(ctx, registry, filepath.Join(root, "develop"))
+	if err != nil || !added || registered != root {
+		t.Fatalf("RegisterRoot = %q, %v, %v; want %q added", registered, added, err, root)
+	}
+	if _, added, err := RegisterRoot(ctx, registry, root); err != nil || added {
+		t.Fatalf("RegisterRoot(again) added = %v, %v", added, err)
+	}
+	if _, _, err := RegisterRoot(ctx, registry, t.TempDir()); err == nil {
+		t.Fatal("expected RegisterRoot to reject a directory outside any gitsej repo")
+	}
+	if roots, err := RegisteredRoots(registry); err != nil || strings.Join(roots, ",") != root {
+		t.Fatalf("RegisteredRoots = %v, %v; want [%s]", roots, err, root)
+	}
+
+	if _, removed, err := UnregisterRoot(ctx, registry, root); err != nil || !removed {
+		t.Fatalf("UnregisterRoot removed = %v, %v", removed, err)
+	}
+	if roots, err := RegisteredRoots(registry); err != nil || len(roots) != 0 {
+		t.Fatalf("RegisteredRoots after unregister = %v, %v", roots, err)
+	}
+}
+
+func TestRunDaemonRefreshesRegisteredRoots(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	cacheDir := t.TempDir()
+	registry := filepath.Join(t.TempDir(), "roots")
+
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "auto_update", Value: "1"}); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if _, _, err := RegisterRoot(ctx, registry, root); err != nil {
+		t.Fatalf("RegisterRoot: %v", err)
+	}
+	origin := filepath.Join(filepath.Dir(root), "origin")
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "upstream")
+
+	var log bytes.Buffer
+	opts := DaemonOptions{RegistryPath: registry, CacheDir: cacheDir, Log: &log, Verbose: true}
+	if err := refreshRegisteredRoots(ctx, opts); err != nil {
+		t.Fatalf("refreshRegisteredRoots: %v", err)
+	}
+	if !strings.Contains(log.String(), root+": main behind=0 dirty=false") {
+		t.Fatalf("daemon log = %q", log.String())
+	}
+
+	head, err := runGitTestOutput(ctx, "-C", mainWorktree.Path, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	upstream, err := runGitTestOutput(ctx, "-C", origin, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	if head != upstream {
+		t.Fatal("expected auto_update to pull the main worktree")
+	}
+
+	// Status served from the daemon's cache must not fetch, however old.
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "later")
+	cached, err := MainStatus(ctx, MainStatusOptions{
+		Root:      root,
+		CacheDir:  cacheDir,
+		CacheOnly: true,
+		Now:       time.Now().Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("MainStatus: %v", err)
+	}
+	if cached.Refreshed || cached.Behind != 0 || cached.CheckedAt.IsZero() {
+		t.Fatalf("cached status = %+v, want the daemon's snapshot", cached)
+	}
+
+	running, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- RunDaemon(running, opts) }()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, ok := DaemonRunning(cacheDir); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("daemon did not write its pid file")
+		}
+	}
+	stop()
+	if err := <-done; err != nil {
+		t.Fatalf("RunDaemon: %v", err)
+	}
+	if _, ok := DaemonRunning(cacheDir); ok {
+		t.Fatal("expected the pid file to be released on exit")
+	}
+}
+
+func TestDaemonRunning(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := t.TempDir()
+	if _, running := DaemonRunning(cacheDir); running {
+		t.Fatal("expected no daemon without a pid file")
+	}
+
+	pidPath := filepath.Join(cacheDir, daemonPIDName)
+	lock, err := lockFile(context.Background(), pidPath, "daemon", 0)
+	if err != nil {
+		t.Fatalf("lockFile: %v", err)
+	}
+	if pid, running := DaemonRunning(cacheDir); !running || pid != os.Getpid() {
+		t.Fatalf("DaemonRunning = %d, %v; want this process", pid, running)
+	}
+	err = RunDaemon(context.Background(), DaemonOptions{RegistryPath: filepath.Join(cacheDir, "roots"), CacheDir: cacheDir})
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("already running (pid %d)", os.Getpid())) {
+		t.Fatalf("RunDaemon error = %v, want already running", err)
+	}
+	lock.Unlock()
+
+	// A pid left behind without the lock, even of a live process, is stale.
+	writeTestFile(t, pidPath, "pid="+strconv.Itoa(os.Getpid())+"\n")
+	if _, running := DaemonRunning(cacheDir); running {
+		t.Fatal("expected an unlocked pid file to be ignored")
+	}
+}
@@ -0,0 +1,370 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type DoctorOptions struct {
+	Directory string
+	Fix       bool
+}
+
+type DoctorResult struct {
+	Directory string
+	Checks    []DoctorCheck
+}
+
+// DoctorCheck is the outcome of one named check. Problem describes what is
+// wrong and stays set when a fix was applied, so reports can show what changed.
+type DoctorCheck struct {
+	Name    string
+	OK      bool
+	Problem string
+	Fixed   bool
+	FixErr  error
+}
+
+// Failed returns the checks that still fail after any fixes.
+func (r DoctorResult) Failed() []DoctorCheck {
+	failed := make([]DoctorCheck, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		if !check.OK {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+type doctorCheck struct {
+	name  string
+	check func(ctx context.Context, root string) (string, error)
+	fix   func(ctx context.Context, root string) error
+}
+
+// doctorChecks run in order; later checks assume earlier ones pass, e.g. the
+// worktree checks need .bare to be a bare repository.
+var doctorChecks = []doctorCheck{
+	{name: "gitdir-file", check: checkGitdirFile, fix: fixGitdirFile},
+	{name: "core-bare", check: checkCoreBare, fix: fixCoreBare},
+	{name: "fetch-refspec", check: checkFetchRefspec, fix: fixFetchRefspec},
+	{name: "worktree-links", check: checkWorktreeLinks, fix: fixWorktreeLinks},
+	{name: "prunable-worktrees", check: checkPrunableWorktrees, fix: fixPrunableWorktrees},
+	{name: "config", check: checkConfig, fix: fixConfig},
+	{name: "partial-clone", check: checkPartialClone},
+}
+
+// Doctor validates a gitsej root and, with opts.Fix, repairs what it can.
+// Unlike most commands it takes the root directory itself, since a broken
+// root may not be discoverable through git.
+func Doctor(ctx context.Context, opts DoctorOptions) (DoctorResult, error) {
+	targetDir := strings.TrimSpace(opts.Directory)
+	if targetDir == "" {
+		targetDir = "."
+	}
+
+	root, err := filepath.Abs(targetDir)
+	if err != nil {
+		return DoctorResult{}, fmt.Errorf("resolve path %s: %w", targetDir, err)
+	}
+
+	bareDir := filepath.Join(root, ".bare")
+	if bareInfo, err := os.Stat(bareDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return DoctorResult{}, fmt.Errorf("missing .bare directory in %s", root)
+		}
+		return DoctorResult{}, fmt.Errorf("check .bare in %s: %w", root, err)
+	} else if !bareInfo.IsDir() {
+		return DoctorResult{}, fmt.Errorf(".bare is not a directory in %s", root)
+	}
+
+	if opts.Fix {
+		lock, err := lockRoot(ctx, root, "doctor --fix")
+		if err != nil {
+			return DoctorResult{}, err
+		}
+		defer lock.Unlock()
+	}
+
+	result := DoctorResult{Directory: root}
+	for _, dc := range doctorChecks {
+		check := DoctorCheck{Name: dc.name}
+
+		problem, err := dc.check(ctx, root)
+		if err != nil {
+			problem = err.Error()
+		}
+		check.Problem = problem
+		check.OK = problem == ""
+
+		if !check.OK && opts.Fix && dc.fix != nil {
+			if err := dc.fix(ctx, root); err != nil {
+				check.FixErr = err
+			} else if after, err := dc.check(ctx, root); err == nil && after == "" {
+				check.OK = true
+				check.Fixed = true
+			} else if err != nil {
+				check.FixErr = err
+			} else {
+				check.FixErr = errors.New(after)
+			}
+		}
+
+		result.Checks = append(result.Checks, check)
+	}
+	return result, nil
+}
+
+func checkGitdirFile(_ context.Context, root string) (string, error) {
+	gitPath := filepath.Join(root, ".git")
+	info, err := os.Stat(gitPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ".git file is missing", nil
+		}
+		return "", fmt.Errorf("check .git: %w", err)
+	}
+	if info.IsDir() {
+		return ".git is a directory; expected a gitdir file pointing to .bare", nil
+	}
+
+	content, err := os.ReadFile(gitPath)
+	if err != nil {
+		return "", fmt.Errorf("read .git: %w", err)
+	}
+	if string(content) != gitdirFileContent() {
+		return fmt.Sprintf(".git contains %q, want %q", strings.TrimSpace(string(content)), strings.TrimSpace(gitdirFileContent())), nil
+	}
+	return "", nil
+}
+
+func fixGitdirFile(_ context.Context, root string) error {
+	gitPath := filepath.Join(root, ".git")
+	if info, err := os.Stat(gitPath); err == nil && info.IsDir() {
+		return errors.New(".git is a directory; refusing to replace it")
+	}
+	if err := os.WriteFile(gitPath, []byte(gitdirFileContent()), 0o644); err != nil {
+		return fmt.Errorf("write .git: %w", err)
+	}
+	return nil
+}
+
+func checkCoreBare(ctx context.Context, root string) (string, error) {
+	out, err := runGitOutput(ctx, "--git-dir", filepath.Join(root, ".bare"), "config", "--bool", "core.bare")
+	if err != nil {
+		return "core.bare is not set", nil
+	}
+	if value := strings.TrimSpace(out); value != "true" {
+		return fmt.Sprintf("core.bare is %s", value), nil
+	}
+	return "", nil
+}
+
+func fixCoreBare(ctx context.Context, root string) error {
+	bareDir := filepath.Join(root, ".bare")
+	if err := runGit(ctx, "--git-dir", bareDir, "config", "core.bare", "true"); err != nil {
+		return err
+	}
+	_ = runGit(ctx, "--git-dir", bareDir, "config", "--unset", "core.worktree")
+	return nil
+}
+
+func checkFetchRefspec(ctx context.Context, root string) (string, error) {
+	hasOrigin, hasRefspec := hasOriginFetchRefspec(ctx, filepath.Join(root, ".bare"))
+	if hasOrigin && !hasRefspec {
+		return "remote.origin.fetch is not configured; origin/* branches never update", nil
+	}
+	return "", nil
+}
+
+// fixFetchRefspec only needs the refspec written; the fetch that fills in
+// origin/* is best-effort so the fix also works offline.
+func fixFetchRefspec(ctx context.Context, root string) error {
+	bareDir := filepath.Join(root, ".bare")
+	if err := writeFetchRefspec(ctx, bareDir); err != nil {
+		return err
+	}
+	_ = fetchOrigin(ctx, bareDir)
+	return nil
+}
+
+func checkWorktreeLinks(ctx context.Context, root string) (string, error) {
+	broken, err := brokenWorktreeLinks(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	if len(broken) == 0 {
+		return "", nil
+	}
+	return "worktree links need repair: " + strings.Join(broken, ", "), nil
+}
+
+func fixWorktreeLinks(ctx context.Context, root string) error {
+	broken, err := brokenWorktreeLinks(ctx, root)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"--git-dir", filepath.Join(root, ".bare"), "worktree", "repair"}, broken...)
+	return runGit(ctx, args...)
+}
+
+// brokenWorktreeLinks returns worktrees whose .git file and the matching
+// .bare/worktrees/<name>/gitdir entry do not point at each other. Worktree
+// directories directly under root are considered even when git has lost track
+// of them, which is what happens after the root is moved.
+func brokenWorktreeLinks(ctx context.Context, root string) ([]string, error) {
+	bareDir := filepath.Join(root, ".bare")
+	adminRoot := canonicalPath(filepath.Join(bareDir, "worktrees"))
+
+	candidates := make([]string, 0, 8)
+	seen := make(map[string]struct{})
+	addCandidate := func(path string) {
+		canonical := canonicalPath(path)
+		if _, ok := seen[canonical]; ok {
+			return
+		}
+		seen[canonical] = struct{}{}
+		candidates = append(candidates, path)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("read directory %s: %w", root, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == ".bare" {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		if info, err := os.Stat(filepath.Join(path, ".git")); err == nil && !info.IsDir() {
+			addCandidate(path)
+		}
+	}
+
+	worktrees, err := listWorktrees(ctx, bareDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range worktrees {
+		if wt.Bare {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(wt.Path, ".git")); err == nil && !info.IsDir() {
+			addCandidate(wt.Path)
+		}
+	}
+
+	broken := make([]string, 0)
+	for _, path := range candidates {
+		if !worktreeLinked(path, adminRoot) {
+			broken = append(broken, path)
+		}
+	}
+	return broken, nil
+}
+
+func worktreeLinked(worktreePath, adminRoot string) bool {
+	gitFile := filepath.Join(worktreePath, ".git")
+	adminDir, err := readGitdirFile(gitFile)
+	if err != nil {
+		return false
+	}
+	adminDir = canonicalPath(adminDir)
+	if filepath.Dir(adminDir) != adminRoot {
+		return false
+	}
+	if info, err := os.Stat(adminDir); err != nil || !info.IsDir() {
+		return false
+	}
+
+	backlink, err := os.ReadFile(filepath.Join(adminDir, "gitdir"))
+	if err != nil {
+		return false
+	}
+	target := strings.TrimSpace(string(backlink))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(adminDir, target)
+	}
+	return canonicalPath(target) == canonicalPath(gitFile)
+}
+
+// readGitdirFile returns the absolute directory named by a "gitdir: <path>"
+// file, resolving relative paths against the file's directory.
+func readGitdirFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("%s is not a gitdir file", path)
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return filepath.Clean(target), nil
+}
+
+func checkPrunableWorktrees(ctx context.Context, root string) (string, error) {
+	worktrees, err := listWorktrees(ctx, filepath.Join(root, ".bare"))
+	if err != nil {
+		return "", err
+	}
+
+	prunable := make([]string, 0)
+	for _, wt := range worktrees {
+		if wt.Prunable {
+			prunable = append(prunable, wt.Path)
+		}
+	}
+	if len(prunable) == 0 {
+		return "", nil
+	}
+	return "stale worktree entries: " + strings.Join(prunable, ", "), nil
+}
+
+func fixPrunableWorktrees(ctx context.Context, root string) error {
+	return runGit(ctx, "--git-dir", filepath.Join(root, ".bare"), "worktree", "prune")
+}
+
+func checkConfig(_ context.Context, root string) (string, error) {
+	var cfg Config
+	if err := cfg.Load(root); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ".gitsej is missing", nil
+		}
+		var cfgErr *ConfigError
+		if errors.As(err, &cfgErr) {
+			return cfgErr.Error(), nil
+		}
+		return "", err
+	}
+
+	var problems []string
+	for _, stray := range cfg.strayLines(filepath.Join(root, ".gitsej")) {
+		problems = append(problems, stray.Error())
+	}
+	return strings.Join(problems, "; "), nil
+}
+
+func fixConfig(ctx context.Context, root string) error {
+	if _, err := os.Stat(filepath.Join(root, ".gitsej")); err == nil {
+		return errors.New("fix malformed .gitsej values by hand or with gitsej config set")
+	}
+	mainBranch := detectRemoteDefaultBranch(ctx, filepath.Join(root, ".bare"))
+	return writeGitsejConfig(root, mainBranch, defaultMainWorktree)
+}
+
+func checkPartialClone(ctx context.Context, root string) (string, error) {
+	cfg, err := loadConfig(root)
+	if err != nil {
+		// Reported by the config check.
+		return "", nil
+	}
+	return partialCloneProblem(ctx, filepath.Join(root, ".bare"), cfg.CloneFilter), nil
+}
@@ -0,0 +1,152 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorPassesHealthyRoot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	result, err := Doctor(ctx, DoctorOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Doctor: %v", err)
+	}
+	if failed := result.Failed(); len(failed) != 0 {
+		t.Fatalf("expected healthy root, failed checks: %+v", failed)
+	}
+	if len(result.Checks) != len(doctorChecks) {
+		t.Fatalf("expected %d checks, got %d", len(doctorChecks), len(result.Checks))
+	}
+}
+
+func TestDoctorFixesBrokenRoot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	original := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: original, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	stale, err := AddWorktree(ctx, AddWorktreeOptions{Directory: original, Branch: "stale"})
+	if err != nil {
+		t.Fatalf("AddWorktree(stale): %v", err)
+	}
+	if err := os.RemoveAll(stale.Path); err != nil {
+		t.Fatalf("remove stale worktree: %v", err)
+	}
+
+	root := filepath.Join(filepath.Dir(original), "moved")
+	if err := os.Rename(original, root); err != nil {
+		t.Fatalf("move root: %v", err)
+	}
+	bareDir := filepath.Join(root, ".bare")
+	if err := os.WriteFile(filepath.Join(root, ".git"), []byte("gitdir: /elsewhere\n"), 0o644); err != nil {
+		t.Fatalf("write .git: %v", err)
+	}
+	runGitTest(t, ctx, "--git-dir", bareDir, "config", "core.bare", "false")
+	runGitTest(t, ctx, "--git-dir", bareDir, "config", "--unset-all", "remote.origin.fetch")
+	if err := os.Remove(filepath.Join(root, ".gitsej")); err != nil {
+		t.Fatalf("remove .gitsej: %v", err)
+	}
+
+	report, err := Doctor(ctx, DoctorOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Doctor: %v", err)
+	}
+	failed := make(map[string]bool)
+	for _, check := range report.Failed() {
+		failed[check.Name] = true
+	}
+	for _, name := range []string{"gitdir-file", "core-bare", "fetch-refspec", "worktree-links", "prunable-worktrees", "config"} {
+		if !failed[name] {
+			t.Fatalf("expected check %s to fail, report: %+v", name, report.Checks)
+		}
+	}
+
+	fixed, err := Doctor(ctx, DoctorOptions{Directory: root, Fix: true})
+	if err != nil {
+		t.Fatalf("Doctor(fix): %v", err)
+	}
+	if failed := fixed.Failed(); len(failed) != 0 {
+		t.Fatalf("expected all checks fixed, failed: %+v", failed)
+	}
+
+	resolved, err := ResolveRoot(ctx, filepath.Join(root, "main"))
+	if err != nil {
+		t.Fatalf("ResolveRoot after fix: %v", err)
+	}
+	if resolved != canonicalPath(root) {
+		t.Fatalf("ResolveRoot = %q, want %q", resolved, canonicalPath(root))
+	}
+
+	again, err := Doctor(ctx, DoctorOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Doctor(again): %v", err)
+	}
+	if failed := again.Failed(); len(failed) != 0 {
+		t.Fatalf("expected healthy root after fix, failed: %+v", failed)
+	}
+}
+
+func TestDoctorReportsStrayConfigLines(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	configPath := filepath.Join(root, ".gitsej")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if err := os.WriteFile(configPath, append(data, "not a setting\n"...), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	// Other commands still load the file.
+	if _, err := loadConfig(root); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	report, err := Doctor(ctx, DoctorOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Doctor: %v", err)
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Name != "config" {
+		t.Fatalf("expected only the config check to fail, failed: %+v", failed)
+	}
+	if !strings.Contains(failed[0].Problem, "expected key=value") {
+		t.Fatalf("unexpected problem: %q", failed[0].Problem)
+	}
+}
+
+func TestDoctorFixesFetchRefspecOffline(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	runGitTest(t, ctx, "--git-dir", filepath.Join(root, ".bare"), "config", "--unset-all", "remote.origin.fetch")
+	if err := os.RemoveAll(filepath.Join(filepath.Dir(root), "origin")); err != nil {
+		t.Fatalf("remove origin: %v", err)
+	}
+
+	result, err := Doctor(ctx, DoctorOptions{Directory: root, Fix: true})
+	if err != nil {
+		t.Fatalf("Doctor: %v", err)
+	}
+	for _, check := range result.Checks {
+		if check.Name == "fetch-refspec" && !check.Fixed {
+			t.Fatalf("expected fetch-refspec to be fixed without origin: %+v", check)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package gitsej
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// hooksDirName is the directory in a gitsej root holding hook
+	// executables named after the event they handle.
+	hooksDirName = ".gitsej-hooks"
+
+	postWorktreeAddHook = "post_worktree_add"
+)
+
+// HookError reports a hook that could not run or exited unsuccessfully. The
+// worktree it ran in has already been created.
+type HookError struct {
+	Hook     string
+	Command  string
+	Worktree string
+	Output   string
+	Err      error
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("%s hook %s failed in %s: %v", e.Hook, e.Command, e.Worktree, e.Err)
+	if e.Output != "" {
+		msg += ": " + e.Output
+	}
+	return msg
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// runPostWorktreeAddHooks runs hook.post_worktree_add from .gitsej through
+// sh, then .gitsej-hooks/post_worktree_add, inside the new worktree. Hook
+// output goes to out; when out is nil it is kept for the error instead.
+func runPostWorktreeAddHooks(ctx context.Context, root string, cfg Config, worktree, branch string, out io.Writer) error {
+	env := append(os.Environ(),
+		"GITSEJ_HOOK="+postWorktreeAddHook,
+		"GITSEJ_ROOT="+root,
+		"GITSEJ_WORKTREE="+worktree,
+		"GITSEJ_BRANCH="+branch,
+		"GITSEJ_MAIN_WORKTREE_PATH="+cfg.MainWorktreePath(root),
+	)
+
+	if command := strings.TrimSpace(cfg.PostWorktreeAddHook); command != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		if err := runHook(cmd, postWorktreeAddHook, command, worktree, env, out); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(root, hooksDirName, postWorktreeAddHook)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return &HookError{Hook: postWorktreeAddHook, Command: path, Worktree: worktree, Err: err}
+	}
+	if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return &HookError{Hook: postWorktreeAddHook, Command: path, Worktree: worktree, Err: errors.New("not an executable file")}
+	}
+	return runHook(exec.CommandContext(ctx, path), postWorktreeAddHook, path, worktree, env, out)
+}
+
+func runHook(cmd *exec.Cmd, hook, command, worktree string, env []string, out io.Writer) error {
+	var captured bytes.Buffer
+	if out == nil {
+		out = &captured
+	}
+	cmd.Dir = worktree
+	cmd.Env = env
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return &HookError{
+			Hook:     hook,
+			Command:  command,
+			Worktree: worktree,
+			Output:   strings.TrimSpace(captured.String()),
+			Err:      err,
+		}
+	}
+	return nil
+}
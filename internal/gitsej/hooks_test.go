@@ -0,0 +1,180 @@
+package gitsej
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddWorktreeRunsPostWorktreeAddHooks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "hook.post_worktree_add", Value: `echo "$GITSEJ_BRANCH $GITSEJ_ROOT" > config-hook.txt`}); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, hooksDirName), 0o755); err != nil {
+		t.Fatalf("create hooks directory: %v", err)
+	}
+	script := "#!/bin/sh\necho dir hook\npwd > \"$GITSEJ_WORKTREE/dir-hook.txt\"\n"
+	if err := os.WriteFile(filepath.Join(root, hooksDirName, postWorktreeAddHook), []byte(script), 0o755); err != nil {
+		t.Fatalf("write hook: %v", err)
+	}
+
+	var out bytes.Buffer
+	result, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "feature/x", HookOutput: &out})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(result.Path, "config-hook.txt"))
+	if err != nil {
+		t.Fatalf("config hook did not run: %v", err)
+	}
+	if want := "feature/x " + root + "\n"; string(got) != want {
+		t.Fatalf("config hook wrote %q, want %q", got, want)
+	}
+	got, err = os.ReadFile(filepath.Join(result.Path, "dir-hook.txt"))
+	if err != nil {
+		t.Fatalf("directory hook did not run: %v", err)
+	}
+	if canonicalPath(strings.TrimSpace(string(got))) != canonicalPath(result.Path) {
+		t.Fatalf("directory hook ran in %q, want %s", got, result.Path)
+	}
+	if out.String() != "dir hook\n" {
+		t.Fatalf("hook output = %q, want %q", out.String(), "dir hook\n")
+	}
+}
+
+func TestFailingPostWorktreeAddHookKeepsWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "hook.post_worktree_add", Value: "echo npm ci failed >&2; exit 3"}); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+
+	result, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"})
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) {
+		t.Fatalf("AddWorktree error = %v, want HookError", err)
+	}
+	if !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "npm ci failed") {
+		t.Fatalf("error does not describe the failure: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(result.Path, "README.md")); err != nil {
+		t.Fatalf("worktree was not kept: %v", err)
+	}
+
+	// A hook that fails must not leave the root locked.
+	if _, err := Sync(ctx, SyncOptions{Directory: root}); err != nil {
+		t.Fatalf("Sync after failed hook: %v", err)
+	}
+}
+
+func TestCreateRecordsAndRunsPostWorktreeAddHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "init")
+
+	repoDir := filepath.Join(base, "repo")
+	hook := `test "$GITSEJ_WORKTREE" = "$GITSEJ_MAIN_WORKTREE_PATH" && touch created.txt`
+	if _, err := Create(ctx, CreateOptions{
+		RepoURL:             origin,
+		Directory:           repoDir,
+		MainWorktree:        true,
+		PostWorktreeAddHook: hook,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "main", "created.txt")); err != nil {
+		t.Fatalf("hook did not run in the main worktree: %v", err)
+	}
+	var cfg Config
+	if err := cfg.Load(repoDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.PostWorktreeAddHook != hook {
+		t.Fatalf("hook.post_worktree_add = %q, want %q", cfg.PostWorktreeAddHook, hook)
+	}
+}
+
+func TestMigrateRecordsPostWorktreeAddHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	runGitTest(t, ctx, "-C", repoDir, "commit", "--allow-empty", "-m", "init")
+
+	hook := "touch migrated.txt"
+	result, err := Migrate(ctx, MigrateOptions{Directory: repoDir, DefaultPostWorktreeAddHook: hook})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if result.Plan.PostWorktreeAddHook != hook {
+		t.Fatalf("plan hook = %q, want %q", result.Plan.PostWorktreeAddHook, hook)
+	}
+	if _, err := os.Stat(filepath.Join(result.CreatedMainWorktree, "migrated.txt")); err != nil {
+		t.Fatalf("hook did not run in the main worktree: %v", err)
+	}
+	var cfg Config
+	if err := cfg.Load(repoDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.PostWorktreeAddHook != hook {
+		t.Fatalf("hook.post_worktree_add = %q, want %q", cfg.PostWorktreeAddHook, hook)
+	}
+}
+
+func TestMigrateKeepsConfiguredPostWorktreeAddHook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	runGitTest(t, ctx, "-C", repoDir, "commit", "--allow-empty", "-m", "init")
+	content := "main_worktree=main\nmain_branch=main\nhook.post_worktree_add=true\n"
+	writeTestFile(t, filepath.Join(repoDir, ".gitsej"), content)
+	writeTestFile(t, filepath.Join(repoDir, ".git", "info", "exclude"), ".gitsej\n")
+
+	// A failed migration must roll back an explicitly given hook too.
+	_, err := Migrate(ctx, MigrateOptions{
+		Directory:           repoDir,
+		MainBranch:          "does-not-exist",
+		PostWorktreeAddHook: "false",
+	})
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Migrate error = %v, want rolled back", err)
+	}
+	got, err := os.ReadFile(filepath.Join(repoDir, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf(".gitsej = %q, want %q", got, content)
+	}
+
+	// The environment default does not replace a hook .gitsej already sets.
+	if _, err := Migrate(ctx, MigrateOptions{Directory: repoDir, DefaultPostWorktreeAddHook: "false"}); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	var cfg Config
+	if err := cfg.Load(repoDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.PostWorktreeAddHook != "true" {
+		t.Fatalf("hook.post_worktree_add = %q, want true", cfg.PostWorktreeAddHook)
+	}
+}
@@ -9,8 +9,9 @@ import (
 )
 
 type InitOptions struct {
-	Directory  string
-	MainBranch string
+	Directory       string
+	MainBranch      string
+	MainWorktreeDir string
 }
 
 type InitResult struct {
@@ -30,6 +31,11 @@ func Init(opts InitOptions) (InitResult, error) {
 		mainBranch = "main"
 	}
 
+	mainWorktreeDir, err := normalizeMainWorktree(opts.MainWorktreeDir)
+	if err != nil {
+		return InitResult{}, err
+	}
+
 	info, err := os.Stat(targetDir)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -69,7 +75,7 @@ func Init(opts InitOptions) (InitResult, error) {
 		if !errors.Is(err, os.ErrNotExist) {
 			return InitResult{}, fmt.Errorf("check .gitsej in %s: %w", targetDir, err)
 		}
-		if err := os.WriteFile(configFile, []byte(gitsejConfigContent(mainBranch)), 0o644); err != nil {
+		if err := os.WriteFile(configFile, []byte(gitsejConfigContent(mainBranch, mainWorktreeDir)), 0o644); err != nil {
 			return InitResult{}, fmt.Errorf("write .gitsej: %w", err)
 		}
 		result.CreatedConfig = true
@@ -97,6 +97,31 @@ func TestInitNoopWhenAlreadyInitialized(t *testing.T) {
 	}
 }
 
+func TestInitWritesMainWorktreeDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".bare"), 0o755); err != nil {
+		t.Fatalf("mkdir .bare: %v", err)
+	}
+
+	if _, err := Init(InitOptions{Directory: dir, MainWorktreeDir: "/srv/checkouts/main"}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	cfgData, err := os.ReadFile(filepath.Join(dir, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if !strings.Contains(string(cfgData), "main_worktree=/srv/checkouts/main\n") {
+		t.Fatalf("expected absolute main_worktree in .gitsej, got:\n%s", string(cfgData))
+	}
+
+	if _, err := Init(InitOptions{Directory: t.TempDir(), MainWorktreeDir: ".bare"}); err == nil {
+		t.Fatalf("expected error for reserved main worktree directory")
+	}
+}
+
 func TestInitFailsWithoutBareDir(t *testing.T) {
 	t.Parallel()
 
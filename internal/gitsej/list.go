@@ -0,0 +1,116 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ListOptions struct {
+	Directory string
+}
+
+type ListResult struct {
+	Root      string           `json:"root"`
+	Worktrees []WorktreeStatus `json:"worktrees"`
+}
+
+type WorktreeStatus struct {
+	Path           string `json:"path"`
+	Head           string `json:"head"`
+	Branch         string `json:"branch,omitempty"`
+	Upstream       string `json:"upstream,omitempty"`
+	Ahead          int    `json:"ahead"`
+	Behind         int    `json:"behind"`
+	Dirty          bool   `json:"dirty"`
+	Main           bool   `json:"main"`
+	Detached       bool   `json:"detached"`
+	Locked         bool   `json:"locked"`
+	LockReason     string `json:"lock_reason,omitempty"`
+	Prunable       bool   `json:"prunable"`
+	PrunableReason string `json:"prunable_reason,omitempty"`
+}
+
+// ListWorktrees reports every non-bare worktree of the gitsej root containing
+// opts.Directory, with ahead/behind counts against each branch's upstream.
+func ListWorktrees(ctx context.Context, opts ListOptions) (ListResult, error) {
+	root, err := ResolveRoot(ctx, opts.Directory)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	worktrees, err := listWorktrees(ctx, root)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	mainWorktreePath, err := configuredMainWorktree(root)
+	if err != nil {
+		return ListResult{}, err
+	}
+	mainCanonical := canonicalPath(mainWorktreePath)
+
+	statuses := make([]WorktreeStatus, 0, len(worktrees))
+	for _, wt := range worktrees {
+		if wt.Bare {
+			continue
+		}
+
+		status := WorktreeStatus{
+			Path:           wt.Path,
+			Head:           wt.Head,
+			Branch:         wt.Branch,
+			Main:           canonicalPath(wt.Path) == mainCanonical,
+			Detached:       wt.Detached,
+			Locked:         wt.Locked,
+			LockReason:     wt.LockReason,
+			Prunable:       wt.Prunable,
+			PrunableReason: wt.PrunableReason,
+		}
+
+		if _, err := os.Stat(wt.Path); err == nil && !wt.Prunable {
+			dirty, err := isWorktreeDirty(ctx, wt.Path)
+			if err != nil {
+				return ListResult{}, err
+			}
+			status.Dirty = dirty
+
+			if wt.Branch != "" {
+				status.Upstream, status.Ahead, status.Behind = upstreamDivergence(ctx, wt.Path)
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+	return ListResult{Root: root, Worktrees: statuses}, nil
+}
+
+// upstreamDivergence returns the upstream of HEAD in worktreePath and how many
+// commits HEAD is ahead of and behind it. The upstream is empty when none is
+// configured or it cannot be resolved.
+func upstreamDivergence(ctx context.Context, worktreePath string) (string, int, int) {
+	upstream, err := runGitOutput(ctx, "-C", worktreePath, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return "", 0, 0
+	}
+	upstream = strings.TrimSpace(upstream)
+
+	counts, err := runGitOutput(ctx, "-C", worktreePath, "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	if err != nil {
+		return "", 0, 0
+	}
+	fields := strings.Fields(counts)
+	if len(fields) != 2 {
+		return "", 0, 0
+	}
+	ahead, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return "", 0, 0
+	}
+	behind, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, 0
+	}
+	return upstream, ahead, behind
+}
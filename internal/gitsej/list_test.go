@@ -0,0 +1,71 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListWorktreesReportsBranchDivergenceAndState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	runGitTest(t, ctx, "-C", mainWorktree.Path, "commit", "--allow-empty", "-m", "local")
+
+	feature, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "feature"})
+	if err != nil {
+		t.Fatalf("AddWorktree(feature): %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(feature.Path, "scratch.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	runGitTest(t, ctx, "-C", root, "worktree", "lock", "--reason", "usb drive", feature.Path)
+
+	result, err := ListWorktrees(ctx, ListOptions{Directory: feature.Path})
+	if err != nil {
+		t.Fatalf("ListWorktrees: %v", err)
+	}
+	if result.Root != root {
+		t.Fatalf("result.Root = %q, want %q", result.Root, root)
+	}
+	if len(result.Worktrees) != 2 {
+		t.Fatalf("expected 2 worktrees, got %+v", result.Worktrees)
+	}
+
+	byBranch := make(map[string]WorktreeStatus, len(result.Worktrees))
+	for _, wt := range result.Worktrees {
+		byBranch[wt.Branch] = wt
+	}
+
+	gotMain := byBranch["main"]
+	if !gotMain.Main {
+		t.Fatalf("expected main worktree to be flagged main: %+v", gotMain)
+	}
+	if gotMain.Upstream != "origin/main" || gotMain.Ahead != 1 || gotMain.Behind != 0 {
+		t.Fatalf("unexpected main divergence: %+v", gotMain)
+	}
+	if gotMain.Dirty {
+		t.Fatalf("did not expect main worktree to be dirty")
+	}
+
+	gotFeature := byBranch["feature"]
+	if gotFeature.Main || !gotFeature.Dirty {
+		t.Fatalf("expected dirty non-main feature worktree: %+v", gotFeature)
+	}
+	if !gotFeature.Locked || gotFeature.LockReason != "usb drive" {
+		t.Fatalf("expected locked feature worktree with reason, got %+v", gotFeature)
+	}
+	if gotFeature.Upstream != "" {
+		t.Fatalf("did not expect upstream for new branch, got %q", gotFeature.Upstream)
+	}
+	if gotFeature.Head == "" {
+		t.Fatalf("expected HEAD to be reported")
+	}
+}
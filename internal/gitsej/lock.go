@@ -0,0 +1,138 @@
+package gitsej
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockFileName is the advisory lock file every mutating gitsej operation
+// holds inside a root's git directory, so concurrent fetches and pulls from
+// gitsej, the daemon and tmux do not trip over git's own lock files.
+const lockFileName = "gitsej.lock"
+
+// RootBusyError reports that another process holds the lock of a gitsej root.
+type RootBusyError struct {
+	PID       int
+	Operation string
+}
+
+func (e *RootBusyError) Error() string {
+	if e.PID == 0 {
+		return "root busy, held by another gitsej process"
+	}
+	return fmt.Sprintf("root busy, held by pid %d running %s", e.PID, e.Operation)
+}
+
+// lockRoot takes the lock of the gitsej root, or the clone being migrated,
+// in dir for operation, waiting up to its lock_timeout.
+func lockRoot(ctx context.Context, dir, operation string) (*rootLock, error) {
+	timeout := defaultLockTimeout
+	if cfg, err := loadConfig(dir); err == nil {
+		timeout = cfg.LockTimeout
+	}
+	return lockGitDir(ctx, migrateGitDir(dir), operation, time.Duration(timeout)*time.Second)
+}
+
+// lockGitDir takes the lock in gitDir, polling until timeout passes or ctx is
+// done. Code that renames gitDir while holding the lock, as migrate and
+// unmigrate do, must use rootLock.rename.
+func lockGitDir(ctx context.Context, gitDir, operation string, timeout time.Duration) (*rootLock, error) {
+	return lockFile(ctx, filepath.Join(gitDir, lockFileName), operation, timeout)
+}
+
+// lockFile takes an exclusive lock on the file at path and records this
+// process and operation in it, so a process that cannot get the lock can
+// report the holder.
+func lockFile(ctx context.Context, path, operation string, timeout time.Duration) (*rootLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			busy := readLockHolder(path)
+			_ = file.Close()
+			return nil, busy
+		}
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	holder := fmt.Sprintf("pid=%d\noperation=%s\n", os.Getpid(), operation)
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(holder), 0)
+	}
+	return &rootLock{file: file, operation: operation, timeout: timeout}, nil
+}
+
+type rootLock struct {
+	file      *os.File
+	operation string
+	timeout   time.Duration
+}
+
+// rename moves the git directory holding the lock from oldPath to newPath.
+// Windows refuses to rename a directory with a file open inside it, so the
+// lock is released for the rename and taken again in whichever directory
+// exists afterwards. A nil lock only renames.
+func (l *rootLock) rename(ctx context.Context, oldPath, newPath string) error {
+	if l == nil || l.file == nil {
+		return os.Rename(oldPath, newPath)
+	}
+	l.Unlock()
+	renameErr := os.Rename(oldPath, newPath)
+	gitDir := oldPath
+	if info, err := os.Stat(newPath); err == nil && info.IsDir() {
+		gitDir = newPath
+	}
+	relocked, err := lockGitDir(ctx, gitDir, l.operation, l.timeout)
+	if err != nil {
+		if renameErr != nil {
+			return renameErr
+		}
+		return fmt.Errorf("lock %s again: %w", gitDir, err)
+	}
+	l.file = relocked.file
+	return renameErr
+}
+
+// Unlock releases the lock. The lock file is left in place: removing it
+// would let a waiting process lock a file nobody else opens again.
+func (l *rootLock) Unlock() {
+	if l == nil || l.file == nil {
+		return
+	}
+	_ = l.file.Truncate(0)
+	_ = unlockFile(l.file)
+	_ = l.file.Close()
+	l.file = nil
+}
+
+func readLockHolder(path string) *RootBusyError {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return &RootBusyError{}
+	}
+	values := parseStateValues(string(content))
+	pid, _ := strconv.Atoi(values["pid"])
+	return &RootBusyError{PID: pid, Operation: strings.TrimSpace(values["operation"])}
+}
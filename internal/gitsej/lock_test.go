@@ -0,0 +1,104 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRootLockReportsHolder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	f, err := os.OpenFile(filepath.Join(root, ".gitsej"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open .gitsej: %v", err)
+	}
+	if _, err := f.WriteString("lock_timeout=0\n"); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+	f.Close()
+
+	held, err := lockGitDir(ctx, filepath.Join(root, ".bare"), "pull", 0)
+	if err != nil {
+		t.Fatalf("lockGitDir: %v", err)
+	}
+
+	_, err = Sync(ctx, SyncOptions{Directory: root})
+	var busy *RootBusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("Sync error = %v, want RootBusyError", err)
+	}
+	want := fmt.Sprintf("root busy, held by pid %d running pull", os.Getpid())
+	if err.Error() != want {
+		t.Fatalf("Sync error = %q, want %q", err, want)
+	}
+
+	status, err := MainStatus(ctx, MainStatusOptions{Root: root, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("MainStatus while locked: %v", err)
+	}
+	if status.Refreshed {
+		t.Fatal("MainStatus refreshed while the root was locked")
+	}
+	if _, err := MainStatus(ctx, MainStatusOptions{Root: root, CacheDir: t.TempDir(), Force: true}); !errors.As(err, &busy) {
+		t.Fatalf("forced MainStatus error = %v, want RootBusyError", err)
+	}
+
+	held.Unlock()
+	if _, err := Sync(ctx, SyncOptions{Directory: root}); err != nil {
+		t.Fatalf("Sync after unlock: %v", err)
+	}
+}
+
+func TestRootLockRenameKeepsLockHeld(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	gitDir := filepath.Join(dir, ".git")
+	barePath := filepath.Join(dir, ".bare")
+	if err := os.Mkdir(gitDir, 0o755); err != nil {
+		t.Fatalf("create .git: %v", err)
+	}
+
+	held, err := lockGitDir(ctx, gitDir, "migrate", 0)
+	if err != nil {
+		t.Fatalf("lockGitDir: %v", err)
+	}
+	defer held.Unlock()
+	if err := held.rename(ctx, gitDir, barePath); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if _, err := os.Stat(gitDir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected .git to be renamed, stat err=%v", err)
+	}
+
+	_, err = lockGitDir(ctx, barePath, "sync", 0)
+	var busy *RootBusyError
+	if !errors.As(err, &busy) || busy.Operation != "migrate" {
+		t.Fatalf("lockGitDir after rename = %v, want RootBusyError held by migrate", err)
+	}
+
+	// A failed rename keeps the lock where it was.
+	if err := held.rename(ctx, gitDir, barePath); err == nil {
+		t.Fatal("expected renaming a missing directory to fail")
+	}
+	if _, err := lockGitDir(ctx, barePath, "sync", 0); !errors.As(err, &busy) {
+		t.Fatalf("lockGitDir after failed rename = %v, want RootBusyError", err)
+	}
+
+	held.Unlock()
+	again, err := lockGitDir(ctx, barePath, "sync", 0)
+	if err != nil {
+		t.Fatalf("lockGitDir after unlock: %v", err)
+	}
+	again.Unlock()
+}
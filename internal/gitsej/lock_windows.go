@@ -0,0 +1,51 @@
+package gitsej
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// lockOverlapped places the locked byte far past the end of the file:
+// Windows locks are mandatory, and the holder written at the start must stay
+// readable for processes waiting on the lock.
+func lockOverlapped() *syscall.Overlapped {
+	return &syscall.Overlapped{Offset: ^uint32(0), OffsetHigh: ^uint32(0) >> 1}
+}
+
+// tryLockFile takes an exclusive LockFileEx lock on file without blocking and
+// reports whether it got it.
+func tryLockFile(file *os.File) (bool, error) {
+	ol := lockOverlapped()
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r != 0 {
+		return true, nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return false, nil
+	}
+	return false, err
+}
+
+func unlockFile(file *os.File) error {
+	ol := lockOverlapped()
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
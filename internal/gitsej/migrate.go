@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
@@ -11,9 +12,24 @@ import (
 )
 
 type MigrateOptions struct {
-	Directory      string
-	MainBranch     string
-	ForceMainClean bool
+	Directory       string
+	MainBranch      string
+	MainWorktreeDir string
+	ForceMainClean  bool
+	PruneMissing    bool
+	CarryChanges    bool
+	KeepIgnored     []string
+	// Sparse names a sparse.<name> profile for the main worktree, or defines
+	// one inline as <name>=<dir>,<dir>.
+	Sparse string
+	// PostWorktreeAddHook is recorded as hook.post_worktree_add before the
+	// post_worktree_add hooks run in the new main worktree.
+	// DefaultPostWorktreeAddHook is recorded instead when it is empty and
+	// .gitsej does not set the hook yet.
+	PostWorktreeAddHook        string
+	DefaultPostWorktreeAddHook string
+	HookOutput                 io.Writer
+	DryRun                     bool
 }
 
 type MigrateResult struct {
@@ -24,6 +40,15 @@ type MigrateResult struct {
 	CreatedMainWorktree   string
 	RemovedRootEntries    []string
 	DetectedDirtyMainPath string
+	CarriedChanges        bool
+	CarryConflicts        []string
+	CarryStash            string
+	CarriedIgnored        []string
+	LockedWorktrees       []WorktreeLock
+	MissingWorktrees      []string
+	PrunedWorktrees       []string
+	DryRun                bool
+	Plan                  MigratePlan
 }
 
 type DirtyMainWorktreeError struct {
@@ -35,11 +60,112 @@ func (e *DirtyMainWorktreeError) Error() string {
 }
 
 type worktreeInfo struct {
-	Path string
-	Bare bool
+	Path           string
+	Head           string
+	Branch         string
+	Bare           bool
+	Detached       bool
+	Locked         bool
+	LockReason     string
+	Prunable       bool
+	PrunableReason string
+}
+
+// MigratePlan describes every change Migrate makes to convert a standard
+// clone. PlanMigrate computes it without touching disk; Migrate executes it.
+type MigratePlan struct {
+	Directory        string
+	MainBranch       string
+	MainWorktreeDir  string
+	MainWorktreePath string
+	MainDirty        bool
+	CarryChanges     bool
+	CreateConfig     bool
+	// ConfigUpdates are written to .gitsej after it is created, or to the
+	// existing file, so it points at the main worktree being created.
+	ConfigUpdates []ConfigEntry
+	// PostWorktreeAddHook is written to the .gitsej that CreateConfig creates.
+	PostWorktreeAddHook string
+	InitSubmodules      bool
+	Sparse              SparseProfile
+	RemoveRootEntries   []string
+	KeptRootEntries     []string
+	KeepIgnored         []string
+	RepairWorktrees     []string
+	WorktreeMoves       []WorktreeMove
+	LockedWorktrees     []WorktreeLock
+	MissingWorktrees    []string
+	PruneWorktrees      []string
+}
+
+type WorktreeMove struct {
+	From   string
+	To     string
+	Locked bool
+}
+
+type WorktreeLock struct {
+	Path   string
+	Reason string
 }
 
 func Migrate(ctx context.Context, opts MigrateOptions) (MigrateResult, error) {
+	plan, err := PlanMigrate(ctx, opts)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	if opts.DryRun {
+		return MigrateResult{
+			Directory:  plan.Directory,
+			MainBranch: plan.MainBranch,
+			DryRun:     true,
+			Plan:       plan,
+		}, nil
+	}
+
+	lock, err := lockRoot(ctx, plan.Directory, "migrate")
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	defer lock.Unlock()
+	// Another process may have changed the clone while we waited.
+	if plan, err = PlanMigrate(ctx, opts); err != nil {
+		return MigrateResult{}, err
+	}
+
+	if plan.MainDirty && !opts.ForceMainClean && !opts.CarryChanges {
+		return MigrateResult{
+			Directory:             plan.Directory,
+			DetectedDirtyMainPath: plan.Directory,
+			Plan:                  plan,
+		}, &DirtyMainWorktreeError{Path: plan.Directory}
+	}
+
+	result, err := executeMigratePlan(ctx, plan, lock)
+	if err != nil {
+		return result, err
+	}
+	lock.Unlock()
+	return result, runMigrateHooks(ctx, result, opts.HookOutput)
+}
+
+// runMigrateHooks runs the post_worktree_add hooks in the main worktree a
+// migration created. The migration itself recorded any hook in .gitsej.
+func runMigrateHooks(ctx context.Context, result MigrateResult, out io.Writer) error {
+	if result.CreatedMainWorktree == "" {
+		return nil
+	}
+	cfg, err := loadConfig(result.Directory)
+	if err != nil {
+		return err
+	}
+	return runPostWorktreeAddHooks(ctx, result.Directory, cfg, result.CreatedMainWorktree, result.MainBranch, out)
+}
+
+// PlanMigrate inspects a standard clone and returns the migration Migrate
+// would perform, without changing anything on disk.
+func PlanMigrate(ctx context.Context, opts MigrateOptions) (MigratePlan, error) {
 	targetDir := strings.TrimSpace(opts.Directory)
 	if targetDir == "" {
 		targetDir = "."
@@ -47,122 +173,182 @@ func Migrate(ctx context.Context, opts MigrateOptions) (MigrateResult, error) {
 
 	absTarget, err := filepath.Abs(targetDir)
 	if err != nil {
-		return MigrateResult{}, fmt.Errorf("resolve path %s: %w", targetDir, err)
+		return MigratePlan{}, fmt.Errorf("resolve path %s: %w", targetDir, err)
 	}
 	canonicalTarget := canonicalPath(absTarget)
 
 	if info, err := os.Stat(absTarget); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return MigrateResult{}, fmt.Errorf("directory does not exist: %s", absTarget)
+			return MigratePlan{}, fmt.Errorf("directory does not exist: %s", absTarget)
 		}
-		return MigrateResult{}, fmt.Errorf("check directory %s: %w", absTarget, err)
+		return MigratePlan{}, fmt.Errorf("check directory %s: %w", absTarget, err)
 	} else if !info.IsDir() {
-		return MigrateResult{}, fmt.Errorf("not a directory: %s", absTarget)
+		return MigratePlan{}, fmt.Errorf("not a directory: %s", absTarget)
+	}
+
+	if migrationInProgress(absTarget) {
+		return MigratePlan{}, fmt.Errorf("migration of %s was interrupted; run gitsej migrate --resume or --abort", absTarget)
 	}
 
 	gitPath := filepath.Join(absTarget, ".git")
 	gitInfo, err := os.Stat(gitPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return MigrateResult{}, fmt.Errorf("missing .git in %s", absTarget)
+			return MigratePlan{}, fmt.Errorf("missing .git in %s", absTarget)
 		}
-		return MigrateResult{}, fmt.Errorf("check .git in %s: %w", absTarget, err)
+		return MigratePlan{}, fmt.Errorf("check .git in %s: %w", absTarget, err)
 	}
 	if !gitInfo.IsDir() {
-		return MigrateResult{}, fmt.Errorf(".git is not a directory in %s; expected standard clone", absTarget)
+		return MigratePlan{}, fmt.Errorf(".git is not a directory in %s; expected standard clone", absTarget)
 	}
 
 	barePath := filepath.Join(absTarget, ".bare")
 	if _, err := os.Stat(barePath); err == nil {
-		return MigrateResult{}, fmt.Errorf(".bare already exists in %s; use gitsej init instead", absTarget)
+		return MigratePlan{}, fmt.Errorf(".bare already exists in %s; use gitsej init instead", absTarget)
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return MigrateResult{}, fmt.Errorf("check .bare in %s: %w", absTarget, err)
+		return MigratePlan{}, fmt.Errorf("check .bare in %s: %w", absTarget, err)
 	}
 
 	worktrees, err := listWorktrees(ctx, absTarget)
 	if err != nil {
-		return MigrateResult{}, err
+		return MigratePlan{}, err
 	}
 
-	dirty, err := isMainWorktreeDirty(ctx, absTarget)
+	dirty, err := isWorktreeDirty(ctx, absTarget)
 	if err != nil {
-		return MigrateResult{}, err
-	}
-	if dirty && !opts.ForceMainClean {
-		return MigrateResult{
-			Directory:             absTarget,
-			DetectedDirtyMainPath: absTarget,
-		}, &DirtyMainWorktreeError{Path: absTarget}
+		return MigratePlan{}, err
 	}
 
+	cfg, cfgErr := loadConfig(absTarget)
 	mainBranch := strings.TrimSpace(opts.MainBranch)
+	if mainBranch == "" && cfgErr == nil && cfg.Has("main_branch") {
+		mainBranch = cfg.MainBranch
+	}
 	if mainBranch == "" {
 		mainBranch, err = detectDefaultBranch(ctx, absTarget)
 		if err != nil {
-			return MigrateResult{}, err
+			return MigratePlan{}, err
 		}
 	}
 
-	if err := os.Rename(gitPath, barePath); err != nil {
-		return MigrateResult{}, fmt.Errorf("move .git to .bare: %w", err)
+	mainWorktreeDir := strings.TrimSpace(opts.MainWorktreeDir)
+	if mainWorktreeDir == "" && cfgErr == nil {
+		mainWorktreeDir = cfg.MainWorktree
+	}
+	keepIgnored := opts.KeepIgnored
+	if len(keepIgnored) == 0 && cfgErr == nil {
+		keepIgnored = cfg.KeepIgnored
+	}
+	mainWorktreeDir, err = normalizeMainWorktree(mainWorktreeDir)
+	if err != nil {
+		return MigratePlan{}, err
 	}
-	if err := os.WriteFile(gitPath, []byte(gitdirFileContent()), 0o644); err != nil {
-		return MigrateResult{}, fmt.Errorf("write .git: %w", err)
+	if cfgErr != nil && strings.TrimSpace(opts.Sparse) != "" {
+		return MigratePlan{}, cfgErr
+	}
+	sparse, err := resolveSparseProfile(cfg, opts.Sparse)
+	if err != nil {
+		return MigratePlan{}, err
+	}
+	mainWorktreePath := resolveMainWorktreePath(absTarget, mainWorktreeDir)
+	hook := strings.TrimSpace(opts.PostWorktreeAddHook)
+	if hook == "" && (cfgErr != nil || !cfg.Has("hook."+postWorktreeAddHook)) {
+		hook = strings.TrimSpace(opts.DefaultPostWorktreeAddHook)
 	}
 
-	if err := runGit(ctx, "--git-dir", barePath, "config", "core.bare", "true"); err != nil {
-		return MigrateResult{}, err
+	plan := MigratePlan{
+		Directory:        absTarget,
+		MainBranch:       mainBranch,
+		MainWorktreeDir:  mainWorktreeDir,
+		MainWorktreePath: mainWorktreePath,
+		MainDirty:        dirty,
+		CarryChanges:     dirty && opts.CarryChanges,
+		InitSubmodules:   runGit(ctx, "-C", absTarget, "cat-file", "-e", mainBranch+":.gitmodules") == nil,
+		Sparse:           sparse,
 	}
-	_ = runGit(ctx, "--git-dir", barePath, "config", "--unset", "core.worktree")
 
-	createdConfig := false
-	configPath := filepath.Join(absTarget, ".gitsej")
-	if _, err := os.Stat(configPath); err != nil {
+	if _, err := os.Stat(filepath.Join(absTarget, ".gitsej")); err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
-			return MigrateResult{}, fmt.Errorf("check .gitsej in %s: %w", absTarget, err)
+			return MigratePlan{}, fmt.Errorf("check .gitsej in %s: %w", absTarget, err)
+		}
+		plan.CreateConfig = true
+		plan.PostWorktreeAddHook = hook
+	} else {
+		if cfgErr != nil {
+			return MigratePlan{}, cfgErr
+		}
+		if cfg.MainBranch != mainBranch {
+			plan.ConfigUpdates = append(plan.ConfigUpdates, ConfigEntry{Key: "main_branch", Value: mainBranch})
 		}
-		if err := os.WriteFile(configPath, []byte(gitsejConfigContent(mainBranch)), 0o644); err != nil {
-			return MigrateResult{}, fmt.Errorf("write .gitsej: %w", err)
+		if cfg.MainWorktree != mainWorktreeDir {
+			plan.ConfigUpdates = append(plan.ConfigUpdates, ConfigEntry{Key: "main_worktree", Value: mainWorktreeDir})
+		}
+		if hook != "" && cfg.PostWorktreeAddHook != hook {
+			plan.ConfigUpdates = append(plan.ConfigUpdates, ConfigEntry{Key: "hook." + postWorktreeAddHook, Value: hook})
 		}
-		createdConfig = true
 	}
 
+	// Top-level entries holding linked worktrees are kept so that the
+	// worktrees survive the cleanup of the old checkout.
+	keep := map[string]struct{}{
+		".bare":      {},
+		".git":       {},
+		".gitsej":    {},
+		hooksDirName: {},
+	}
 	for _, wt := range worktrees {
 		wtCanonical := canonicalPath(wt.Path)
 		if wt.Bare || wtCanonical == canonicalTarget {
 			continue
 		}
+		if wt.Locked {
+			plan.LockedWorktrees = append(plan.LockedWorktrees, WorktreeLock{Path: wt.Path, Reason: wt.LockReason})
+		}
 		if _, err := os.Stat(wt.Path); err != nil {
+			// Locked entries are never pruned; git keeps them for worktrees
+			// on drives that are not mounted right now.
+			plan.MissingWorktrees = append(plan.MissingWorktrees, wt.Path)
+			if opts.PruneMissing && !wt.Locked {
+				plan.PruneWorktrees = append(plan.PruneWorktrees, wt.Path)
+			}
 			continue
 		}
-		_ = runGit(ctx, "--git-dir", barePath, "worktree", "repair", wt.Path)
+		plan.RepairWorktrees = append(plan.RepairWorktrees, wt.Path)
+		if rel, err := filepath.Rel(canonicalTarget, wtCanonical); err == nil && !strings.HasPrefix(rel, "..") {
+			top := strings.Split(rel, string(os.PathSeparator))[0]
+			if _, ok := keep[top]; !ok {
+				keep[top] = struct{}{}
+				plan.KeptRootEntries = append(plan.KeptRootEntries, top)
+			}
+		}
 	}
 
-	keep := map[string]struct{}{
-		".bare":   {},
-		".git":    {},
-		".gitsej": {},
-	}
-	removedEntries, err := cleanRootDirectory(absTarget, keep)
+	entries, err := os.ReadDir(absTarget)
 	if err != nil {
-		return MigrateResult{}, err
+		return MigratePlan{}, fmt.Errorf("read directory %s: %w", absTarget, err)
+	}
+	for _, entry := range entries {
+		if _, ok := keep[entry.Name()]; !ok {
+			plan.RemoveRootEntries = append(plan.RemoveRootEntries, entry.Name())
+		}
 	}
 
-	mainWorktreePath := filepath.Join(absTarget, "main")
-	if err := runGit(ctx, "--git-dir", barePath, "worktree", "add", "--force", mainWorktreePath, mainBranch); err != nil {
-		return MigrateResult{}, fmt.Errorf("create main worktree from %s: %w", mainBranch, err)
+	if rel, err := filepath.Rel(absTarget, mainWorktreePath); err == nil && !strings.HasPrefix(rel, "..") {
+		top := strings.Split(rel, string(os.PathSeparator))[0]
+		if _, kept := keep[top]; kept {
+			return MigratePlan{}, fmt.Errorf("main worktree path %s conflicts with kept entry %s", mainWorktreePath, top)
+		}
+	} else if _, err := os.Stat(mainWorktreePath); err == nil {
+		return MigratePlan{}, fmt.Errorf("main worktree path already exists: %s", mainWorktreePath)
+	}
+
+	if len(keepIgnored) > 0 {
+		plan.KeepIgnored, err = matchIgnoredFiles(ctx, absTarget, keepIgnored, keep)
+		if err != nil {
+			return MigratePlan{}, err
+		}
 	}
-	_ = runGit(
-		ctx,
-		"-C",
-		mainWorktreePath,
-		"branch",
-		"--set-upstream-to",
-		"origin/"+mainBranch,
-		mainBranch,
-	)
 
-	moved := make([]string, 0, len(worktrees))
 	usedDestinations := map[string]struct{}{
 		filepath.Clean(mainWorktreePath): {},
 	}
@@ -181,46 +367,56 @@ func Migrate(ctx context.Context, opts MigrateOptions) (MigrateResult, error) {
 
 		destPath, err := nextWorktreeDestination(absTarget, filepath.Base(oldPath), usedDestinations)
 		if err != nil {
-			return MigrateResult{}, err
-		}
-		if err := runGit(ctx, "--git-dir", barePath, "worktree", "move", oldPath, destPath); err != nil {
-			return MigrateResult{}, fmt.Errorf("move worktree %s to %s: %w", oldPath, destPath, err)
+			return MigratePlan{}, err
 		}
 		usedDestinations[filepath.Clean(destPath)] = struct{}{}
-		moved = append(moved, destPath)
+		plan.WorktreeMoves = append(plan.WorktreeMoves, WorktreeMove{From: oldPath, To: destPath, Locked: wt.Locked})
 	}
 
-	slices.Sort(moved)
-	slices.Sort(removedEntries)
-
-	return MigrateResult{
-		Directory:           absTarget,
-		MainBranch:          mainBranch,
-		CreatedConfig:       createdConfig,
-		MovedWorktrees:      moved,
-		CreatedMainWorktree: mainWorktreePath,
-		RemovedRootEntries:  removedEntries,
-	}, nil
+	slices.Sort(plan.RemoveRootEntries)
+	slices.Sort(plan.KeptRootEntries)
+	slices.Sort(plan.MissingWorktrees)
+	slices.Sort(plan.PruneWorktrees)
+	return plan, nil
 }
 
-func cleanRootDirectory(dir string, keep map[string]struct{}) ([]string, error) {
-	entries, err := os.ReadDir(dir)
+// matchIgnoredFiles returns the ignored paths of the checkout in dir matching
+// any of patterns. A pattern matches either the whole root-relative path or
+// its last element, so "node_modules" also matches "web/node_modules".
+// Paths under kept root entries are skipped since they are not removed.
+func matchIgnoredFiles(ctx context.Context, dir string, patterns []string, keep map[string]struct{}) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid keep-ignored pattern %q: %w", pattern, err)
+		}
+	}
+
+	out, err := runGitOutput(ctx, "-C", dir, "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory")
 	if err != nil {
-		return nil, fmt.Errorf("read directory %s: %w", dir, err)
+		return nil, err
 	}
 
-	removed := make([]string, 0, len(entries))
-	for _, entry := range entries {
-		name := entry.Name()
-		if _, ok := keep[name]; ok {
+	matched := make([]string, 0)
+	for _, path := range strings.Split(out, "\x00") {
+		path = strings.TrimSuffix(path, "/")
+		if path == "" {
 			continue
 		}
-		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
-			return nil, fmt.Errorf("remove %s: %w", filepath.Join(dir, name), err)
+		top, _, _ := strings.Cut(path, "/")
+		if _, kept := keep[top]; kept {
+			continue
+		}
+		for _, pattern := range patterns {
+			fullMatch, _ := filepath.Match(pattern, path)
+			baseMatch, _ := filepath.Match(pattern, filepath.Base(path))
+			if fullMatch || baseMatch {
+				matched = append(matched, filepath.FromSlash(path))
+				break
+			}
 		}
-		removed = append(removed, name)
 	}
-	return removed, nil
+	slices.Sort(matched)
+	return matched, nil
 }
 
 func nextWorktreeDestination(root, base string, used map[string]struct{}) (string, error) {
@@ -275,7 +471,7 @@ func detectDefaultBranch(ctx context.Context, repoDir string) (string, error) {
 	return "main", nil
 }
 
-func isMainWorktreeDirty(ctx context.Context, repoDir string) (bool, error) {
+func isWorktreeDirty(ctx context.Context, repoDir string) (bool, error) {
 	out, err := runGitOutput(ctx, "-C", repoDir, "status", "--porcelain")
 	if err != nil {
 		return false, err
@@ -283,6 +479,22 @@ func isMainWorktreeDirty(ctx context.Context, repoDir string) (bool, error) {
 	return strings.TrimSpace(out) != "", nil
 }
 
+// unmergedPaths returns the paths with merge conflicts in dir, read
+// NUL-separated so names with spaces or non-ASCII characters stay intact.
+func unmergedPaths(ctx context.Context, dir string) ([]string, error) {
+	out, err := runGitOutput(ctx, "-C", dir, "diff", "-z", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
 func listWorktrees(ctx context.Context, repoDir string) ([]worktreeInfo, error) {
 	out, err := runGitOutput(ctx, "-C", repoDir, "worktree", "list", "--porcelain")
 	if err != nil {
@@ -312,8 +524,22 @@ func listWorktrees(ctx context.Context, repoDir string) ([]worktreeInfo, error)
 			haveCurrent = true
 			continue
 		}
-		if line == "bare" {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "HEAD":
+			current.Head = value
+		case "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "bare":
 			current.Bare = true
+		case "detached":
+			current.Detached = true
+		case "locked":
+			current.Locked = true
+			current.LockReason = value
+		case "prunable":
+			current.Prunable = true
+			current.PrunableReason = value
 		}
 	}
 	if haveCurrent {
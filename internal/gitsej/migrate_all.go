@@ -0,0 +1,201 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultMigrateAllDepth = 3
+	defaultMigrateAllJobs  = 4
+)
+
+type MigrationStatus string
+
+const (
+	MigrationMigrated     MigrationStatus = "migrated"
+	MigrationPlanned      MigrationStatus = "planned"
+	MigrationSkippedDirty MigrationStatus = "skipped-dirty"
+	MigrationSkippedRoot  MigrationStatus = "skipped-gitsej"
+	MigrationFailed       MigrationStatus = "failed"
+)
+
+// MigrateAllOptions configures MigrateAll. Migrate is applied to every clone
+// found, with its Directory replaced.
+type MigrateAllOptions struct {
+	Directory string
+	MaxDepth  int
+	Jobs      int
+	Migrate   MigrateOptions
+}
+
+type MigrateAllResult struct {
+	Directory string
+	Repos     []RepoMigration
+}
+
+type RepoMigration struct {
+	Directory string
+	Status    MigrationStatus
+	Result    MigrateResult
+	Err       error
+}
+
+// Count returns the number of repositories with the given status.
+func (r MigrateAllResult) Count(status MigrationStatus) int {
+	n := 0
+	for _, repo := range r.Repos {
+		if repo.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// MigrateAll migrates every standard clone found under opts.Directory, at most
+// opts.MaxDepth levels deep, running up to opts.Jobs migrations at once.
+// Existing gitsej roots are skipped, and so are clones with a dirty checkout
+// unless opts.Migrate allows cleaning or carrying the changes. Failures are
+// reported per repository rather than returned.
+func MigrateAll(ctx context.Context, opts MigrateAllOptions) (MigrateAllResult, error) {
+	targetDir := strings.TrimSpace(opts.Directory)
+	if targetDir == "" {
+		targetDir = "."
+	}
+	absTarget, err := filepath.Abs(targetDir)
+	if err != nil {
+		return MigrateAllResult{}, fmt.Errorf("resolve path %s: %w", targetDir, err)
+	}
+	if info, err := os.Stat(absTarget); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return MigrateAllResult{}, fmt.Errorf("directory does not exist: %s", absTarget)
+		}
+		return MigrateAllResult{}, fmt.Errorf("check directory %s: %w", absTarget, err)
+	} else if !info.IsDir() {
+		return MigrateAllResult{}, fmt.Errorf("not a directory: %s", absTarget)
+	}
+	// The same options apply to every clone, so a main worktree outside the
+	// clone would be created at one shared path by all of them.
+	if dir := strings.TrimSpace(opts.Migrate.MainWorktreeDir); dir != "" {
+		if filepath.IsAbs(dir) || filepath.Clean(dir) == ".." || strings.HasPrefix(filepath.Clean(dir), ".."+string(os.PathSeparator)) {
+			return MigrateAllResult{}, fmt.Errorf("main worktree dir %s is outside the clone and cannot be used with --recursive", dir)
+		}
+	}
+
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMigrateAllDepth
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = defaultMigrateAllJobs
+	}
+
+	clones, roots, err := findStandardClones(absTarget, maxDepth)
+	if err != nil {
+		return MigrateAllResult{}, err
+	}
+
+	result := MigrateAllResult{Directory: absTarget}
+	for _, root := range roots {
+		result.Repos = append(result.Repos, RepoMigration{Directory: root, Status: MigrationSkippedRoot})
+	}
+
+	migrated := make([]RepoMigration, len(clones))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, clone := range clones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			migrated[i] = migrateOne(ctx, clone, opts.Migrate)
+		}()
+	}
+	wg.Wait()
+
+	result.Repos = append(result.Repos, migrated...)
+	slices.SortFunc(result.Repos, func(a, b RepoMigration) int {
+		return strings.Compare(a.Directory, b.Directory)
+	})
+	return result, nil
+}
+
+func migrateOne(ctx context.Context, dir string, opts MigrateOptions) RepoMigration {
+	repo := RepoMigration{Directory: dir}
+	if err := ctx.Err(); err != nil {
+		repo.Status = MigrationFailed
+		repo.Err = err
+		return repo
+	}
+
+	opts.Directory = dir
+	result, err := Migrate(ctx, opts)
+	repo.Result = result
+
+	var dirtyErr *DirtyMainWorktreeError
+	switch {
+	case errors.As(err, &dirtyErr):
+		repo.Status = MigrationSkippedDirty
+		repo.Err = err
+	case err != nil:
+		repo.Status = MigrationFailed
+		repo.Err = err
+	case result.DryRun:
+		repo.Status = MigrationPlanned
+	default:
+		repo.Status = MigrationMigrated
+	}
+	return repo
+}
+
+// findStandardClones walks dir up to maxDepth levels and returns directories
+// holding a .git directory, plus gitsej roots (those with .bare). Neither is
+// descended into, and neither are hidden directories.
+func findStandardClones(dir string, maxDepth int) ([]string, []string, error) {
+	var clones, roots []string
+
+	var walk func(path string, depth int) error
+	walk = func(path string, depth int) error {
+		if info, err := os.Stat(filepath.Join(path, ".bare")); err == nil && info.IsDir() {
+			roots = append(roots, path)
+			return nil
+		}
+		if info, err := os.Stat(filepath.Join(path, ".git")); err == nil && info.IsDir() {
+			clones = append(clones, path)
+			return nil
+		}
+		if depth >= maxDepth {
+			return nil
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			if path == dir {
+				return fmt.Errorf("read directory %s: %w", path, err)
+			}
+			return nil
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if err := walk(filepath.Join(path, entry.Name()), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(dir, 0); err != nil {
+		return nil, nil, err
+	}
+	return clones, roots, nil
+}
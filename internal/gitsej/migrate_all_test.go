@@ -0,0 +1,103 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateAllMigratesClonesUnderDirectory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := canonicalPath(t.TempDir())
+
+	newClone := func(rel string) string {
+		dir := filepath.Join(base, rel)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", rel, err)
+		}
+		runGitTest(t, ctx, "init", "-b", "main", dir)
+		writeTestFile(t, filepath.Join(dir, "README.md"), "hello\n")
+		runGitTest(t, ctx, "-C", dir, "add", "README.md")
+		runGitTest(t, ctx, "-C", dir, "commit", "-m", "init")
+		return dir
+	}
+
+	clean := newClone("clean")
+	nested := newClone("team/service")
+	dirty := newClone("dirty")
+	writeTestFile(t, filepath.Join(dirty, "README.md"), "changed\n")
+	converted := newClone("converted")
+	if _, err := Migrate(ctx, MigrateOptions{Directory: converted}); err != nil {
+		t.Fatalf("Migrate(converted): %v", err)
+	}
+	tooDeep := newClone("a/b/c/deep")
+	if err := os.MkdirAll(filepath.Join(base, "notes"), 0o755); err != nil {
+		t.Fatalf("mkdir notes: %v", err)
+	}
+
+	result, err := MigrateAll(ctx, MigrateAllOptions{
+		Directory: base,
+		MaxDepth:  2,
+		Jobs:      2,
+	})
+	if err != nil {
+		t.Fatalf("MigrateAll: %v", err)
+	}
+
+	want := map[string]MigrationStatus{
+		clean:     MigrationMigrated,
+		nested:    MigrationMigrated,
+		dirty:     MigrationSkippedDirty,
+		converted: MigrationSkippedRoot,
+	}
+	if len(result.Repos) != len(want) {
+		t.Fatalf("result.Repos = %+v, want %d repos", result.Repos, len(want))
+	}
+	for _, repo := range result.Repos {
+		if status, ok := want[repo.Directory]; !ok || repo.Status != status {
+			t.Fatalf("repo %s status = %s (err=%v), want %s", repo.Directory, repo.Status, repo.Err, status)
+		}
+	}
+	if got := result.Count(MigrationMigrated); got != 2 {
+		t.Fatalf("Count(migrated) = %d, want 2", got)
+	}
+
+	for _, dir := range []string{clean, nested} {
+		if _, err := os.Stat(filepath.Join(dir, ".bare")); err != nil {
+			t.Fatalf("expected %s to be migrated: %v", dir, err)
+		}
+	}
+	for _, dir := range []string{dirty, tooDeep} {
+		if info, err := os.Stat(filepath.Join(dir, ".git")); err != nil || !info.IsDir() {
+			t.Fatalf("expected %s to stay a standard clone, err=%v", dir, err)
+		}
+	}
+}
+
+func TestMigrateAllRejectsSharedMainWorktreeDir(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := canonicalPath(t.TempDir())
+	clone := filepath.Join(base, "clone")
+	runGitTest(t, ctx, "init", "-b", "main", clone)
+	runGitTest(t, ctx, "-C", clone, "commit", "--allow-empty", "-m", "init")
+
+	for _, dir := range []string{filepath.Join(base, "main"), "../main"} {
+		_, err := MigrateAll(ctx, MigrateAllOptions{
+			Directory: base,
+			Migrate:   MigrateOptions{MainWorktreeDir: dir},
+		})
+		if err == nil || !strings.Contains(err.Error(), "cannot be used with --recursive") {
+			t.Fatalf("MigrateAll(%s) error = %v, want rejection", dir, err)
+		}
+		if _, err := os.Stat(filepath.Join(clone, ".bare")); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected clone to be untouched, stat err=%v", err)
+		}
+	}
+}
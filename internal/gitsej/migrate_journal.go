@@ -0,0 +1,766 @@
+package gitsej
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	migrateJournalName = "gitsej-migrate.json"
+	migrateStashName   = "gitsej-migrate-stash"
+)
+
+// ErrNoMigrationInProgress is returned by ResumeMigrate and AbortMigrate when
+// the directory has no migration journal.
+var ErrNoMigrationInProgress = errors.New("no interrupted migration found")
+
+// migrateJournal records the plan and the progress of a migration. It lives in
+// the repository's git directory, so it follows .git when it is renamed to
+// .bare and is removed once the migration completes or is rolled back.
+type migrateJournal struct {
+	Plan  MigratePlan   `json:"plan"`
+	Steps []journalStep `json:"steps"`
+	// lock is the root lock held while the journal runs; it has to be
+	// released while .git is renamed.
+	lock *rootLock
+}
+
+// journalStep is written before a step runs and marked done afterwards, so a
+// step that was interrupted midway is still rolled back.
+type journalStep struct {
+	Name  string   `json:"name"`
+	Done  bool     `json:"done"`
+	Value string   `json:"value,omitempty"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+type migrateStep struct {
+	name     string
+	apply    func(ctx context.Context, j *migrateJournal, step *journalStep) error
+	rollback func(ctx context.Context, j *migrateJournal, step journalStep) error
+}
+
+// ResumeMigrate continues an interrupted migration from its journal, then
+// runs the post_worktree_add hooks with their output written to hookOutput.
+func ResumeMigrate(ctx context.Context, directory string, hookOutput io.Writer) (MigrateResult, error) {
+	j, err := loadMigrateJournal(directory)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	lock, err := lockRoot(ctx, j.Plan.Directory, "migrate --resume")
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	defer lock.Unlock()
+	j.lock = lock
+	result, err := j.run(ctx)
+	if err != nil {
+		return result, err
+	}
+	lock.Unlock()
+	return result, runMigrateHooks(ctx, result, hookOutput)
+}
+
+// AbortMigrate rolls back an interrupted migration, restoring the standard
+// clone it started from.
+func AbortMigrate(ctx context.Context, directory string) error {
+	j, err := loadMigrateJournal(directory)
+	if err != nil {
+		return err
+	}
+	lock, err := lockRoot(ctx, j.Plan.Directory, "migrate --abort")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	j.lock = lock
+	if err := j.rollback(ctx, migrateSteps(j.Plan)); err != nil {
+		return fmt.Errorf("abort migration: %w", err)
+	}
+	return nil
+}
+
+func executeMigratePlan(ctx context.Context, plan MigratePlan, lock *rootLock) (MigrateResult, error) {
+	j := &migrateJournal{Plan: plan, lock: lock}
+	if err := j.save(); err != nil {
+		return MigrateResult{}, err
+	}
+	return j.run(ctx)
+}
+
+// run applies the remaining steps. On failure the completed steps are rolled
+// back; if that fails too the journal is kept for --abort.
+func (j *migrateJournal) run(ctx context.Context) (MigrateResult, error) {
+	steps := migrateSteps(j.Plan)
+	if err := j.apply(ctx, steps); err != nil {
+		if rollbackErr := j.rollback(ctx, steps); rollbackErr != nil {
+			return MigrateResult{}, fmt.Errorf("%w; rollback failed: %v; run gitsej migrate --abort to retry", err, rollbackErr)
+		}
+		return MigrateResult{}, fmt.Errorf("%w; migration rolled back", err)
+	}
+	return j.finish()
+}
+
+func (j *migrateJournal) apply(ctx context.Context, steps []migrateStep) error {
+	for _, step := range steps {
+		entry := j.step(step.name)
+		if entry != nil && entry.Done {
+			continue
+		}
+		if entry == nil {
+			j.Steps = append(j.Steps, journalStep{Name: step.name})
+			entry = &j.Steps[len(j.Steps)-1]
+		}
+		if err := step.apply(ctx, j, entry); err != nil {
+			return err
+		}
+		entry.Done = true
+		if err := j.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback undoes the journaled steps in reverse order and removes the
+// journal once everything is restored.
+func (j *migrateJournal) rollback(ctx context.Context, steps []migrateStep) error {
+	for i := len(j.Steps) - 1; i >= 0; i-- {
+		entry := j.Steps[i]
+		idx := slices.IndexFunc(steps, func(s migrateStep) bool { return s.name == entry.Name })
+		if idx < 0 {
+			return fmt.Errorf("unknown migration step %q in journal", entry.Name)
+		}
+		if steps[idx].rollback != nil {
+			if err := steps[idx].rollback(ctx, j, entry); err != nil {
+				return fmt.Errorf("roll back %s: %w", entry.Name, err)
+			}
+		}
+		j.Steps = j.Steps[:i]
+		if err := j.save(); err != nil {
+			return err
+		}
+	}
+	return j.remove()
+}
+
+func (j *migrateJournal) finish() (MigrateResult, error) {
+	plan := j.Plan
+	if err := os.RemoveAll(filepath.Join(plan.Directory, ".bare", migrateStashName)); err != nil {
+		return MigrateResult{}, fmt.Errorf("remove old root entries: %w", err)
+	}
+	if err := j.remove(); err != nil {
+		return MigrateResult{}, err
+	}
+
+	moved := make([]string, 0, len(plan.WorktreeMoves))
+	for _, move := range plan.WorktreeMoves {
+		moved = append(moved, move.To)
+	}
+	slices.Sort(moved)
+
+	result := MigrateResult{
+		Directory:           plan.Directory,
+		MainBranch:          plan.MainBranch,
+		CreatedConfig:       plan.CreateConfig,
+		MovedWorktrees:      moved,
+		CreatedMainWorktree: plan.MainWorktreePath,
+		RemovedRootEntries:  slices.Clone(plan.RemoveRootEntries),
+		MissingWorktrees:    slices.Clone(plan.MissingWorktrees),
+		Plan:                plan,
+	}
+	for _, lock := range plan.LockedWorktrees {
+		for _, move := range plan.WorktreeMoves {
+			if move.From == lock.Path {
+				lock.Path = move.To
+			}
+		}
+		result.LockedWorktrees = append(result.LockedWorktrees, lock)
+	}
+	slices.SortFunc(result.LockedWorktrees, func(a, b WorktreeLock) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	if step := j.step("prune-worktrees"); step != nil {
+		result.PrunedWorktrees = slices.Clone(step.Paths)
+	}
+	for _, rel := range plan.KeepIgnored {
+		if step := j.step("keep-ignored:" + rel); step != nil && step.Value != "" {
+			result.CarriedIgnored = append(result.CarriedIgnored, rel)
+		}
+	}
+	if applied := j.step("apply-changes"); applied != nil {
+		result.CarriedChanges = true
+		result.CarryConflicts = slices.Clone(applied.Paths)
+		if len(applied.Paths) > 0 {
+			result.CarryStash = j.step("stash-changes").Value
+		}
+	}
+	return result, nil
+}
+
+func (j *migrateJournal) step(name string) *journalStep {
+	for i := range j.Steps {
+		if j.Steps[i].Name == name {
+			return &j.Steps[i]
+		}
+	}
+	return nil
+}
+
+func (j *migrateJournal) save() error {
+	path := migrateJournalPath(j.Plan.Directory)
+	content, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode migration journal: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), migrateJournalName+".*")
+	if err != nil {
+		return fmt.Errorf("write migration journal: %w", err)
+	}
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write migration journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write migration journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write migration journal: %w", err)
+	}
+	return nil
+}
+
+func (j *migrateJournal) remove() error {
+	if err := os.Remove(migrateJournalPath(j.Plan.Directory)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove migration journal: %w", err)
+	}
+	return nil
+}
+
+// migrateGitDir returns the repository directory of a clone being migrated:
+// .bare once it has been renamed, .git before that.
+func migrateGitDir(dir string) string {
+	barePath := filepath.Join(dir, ".bare")
+	if info, err := os.Stat(barePath); err == nil && info.IsDir() {
+		return barePath
+	}
+	return filepath.Join(dir, ".git")
+}
+
+func migrateJournalPath(dir string) string {
+	return filepath.Join(migrateGitDir(dir), migrateJournalName)
+}
+
+func migrationInProgress(dir string) bool {
+	for _, name := range []string{".git", ".bare"} {
+		if _, err := os.Stat(filepath.Join(dir, name, migrateJournalName)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func loadMigrateJournal(directory string) (*migrateJournal, error) {
+	dir, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, fmt.Errorf("resolve path %s: %w", directory, err)
+	}
+
+	content, err := os.ReadFile(migrateJournalPath(dir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w in %s", ErrNoMigrationInProgress, dir)
+		}
+		return nil, fmt.Errorf("read migration journal: %w", err)
+	}
+
+	var j migrateJournal
+	if err := json.Unmarshal(content, &j); err != nil {
+		return nil, fmt.Errorf("parse migration journal: %w", err)
+	}
+	return &j, nil
+}
+
+// migrateSteps lists the steps of a migration in order. Every apply is safe to
+// repeat after an interruption and every rollback tolerates a step that only
+// partially ran.
+func migrateSteps(plan MigratePlan) []migrateStep {
+	dir := plan.Directory
+	gitPath := filepath.Join(dir, ".git")
+	barePath := filepath.Join(dir, ".bare")
+	stashPath := filepath.Join(barePath, migrateStashName)
+
+	steps := make([]migrateStep, 0, 8)
+
+	// Uncommitted changes are stashed before anything moves and applied in the
+	// new main worktree once it exists; rolling back applies them to the
+	// restored checkout again.
+	if plan.CarryChanges {
+		steps = append(steps, migrateStep{
+			name: "stash-changes",
+			apply: func(ctx context.Context, j *migrateJournal, step *journalStep) error {
+				if step.Value != "" {
+					return nil
+				}
+				if err := runGit(ctx, "-C", dir, "stash", "push", "--include-untracked", "-m", "gitsej migrate"); err != nil {
+					return fmt.Errorf("stash uncommitted changes: %w", err)
+				}
+				out, err := runGitOutput(ctx, "-C", dir, "rev-parse", "refs/stash")
+				if err != nil {
+					return fmt.Errorf("resolve stash: %w", err)
+				}
+				step.Value = strings.TrimSpace(out)
+				return j.save()
+			},
+			rollback: func(ctx context.Context, _ *migrateJournal, step journalStep) error {
+				if step.Value == "" {
+					return nil
+				}
+				if err := runGit(ctx, "-C", dir, "stash", "apply", "--index", step.Value); err != nil {
+					return fmt.Errorf("restore uncommitted changes from stash %s: %w", step.Value, err)
+				}
+				return dropStash(ctx, dir, step.Value)
+			},
+		})
+	}
+
+	steps = append(steps, []migrateStep{
+		{
+			name: "convert-git",
+			apply: func(ctx context.Context, j *migrateJournal, _ *journalStep) error {
+				if info, err := os.Stat(gitPath); err == nil && info.IsDir() {
+					if err := j.lock.rename(ctx, gitPath, barePath); err != nil {
+						return fmt.Errorf("move .git to .bare: %w", err)
+					}
+				}
+				if err := os.WriteFile(gitPath, []byte(gitdirFileContent()), 0o644); err != nil {
+					return fmt.Errorf("write .git: %w", err)
+				}
+				return nil
+			},
+			rollback: func(ctx context.Context, j *migrateJournal, _ journalStep) error {
+				if info, err := os.Stat(barePath); err != nil || !info.IsDir() {
+					return nil
+				}
+				if info, err := os.Stat(gitPath); err == nil && !info.IsDir() {
+					if err := os.Remove(gitPath); err != nil {
+						return fmt.Errorf("remove .git file: %w", err)
+					}
+				}
+				if err := j.lock.rename(ctx, barePath, gitPath); err != nil {
+					return fmt.Errorf("move .bare back to .git: %w", err)
+				}
+				if len(plan.RepairWorktrees) > 0 {
+					args := append([]string{"-C", dir, "worktree", "repair"}, plan.RepairWorktrees...)
+					if err := runGit(ctx, args...); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "core-bare",
+			apply: func(ctx context.Context, j *migrateJournal, step *journalStep) error {
+				if step.Value == "" {
+					if out, err := runGitOutput(ctx, "--git-dir", barePath, "config", "core.worktree"); err == nil {
+						step.Value = strings.TrimSpace(out)
+						if err := j.save(); err != nil {
+							return err
+						}
+					}
+				}
+				if err := runGit(ctx, "--git-dir", barePath, "config", "core.bare", "true"); err != nil {
+					return err
+				}
+				_ = runGit(ctx, "--git-dir", barePath, "config", "--unset", "core.worktree")
+				return nil
+			},
+			rollback: func(ctx context.Context, _ *migrateJournal, step journalStep) error {
+				gitDir := migrateGitDir(dir)
+				if err := runGit(ctx, "--git-dir", gitDir, "config", "core.bare", "false"); err != nil {
+					return err
+				}
+				if step.Value != "" {
+					return runGit(ctx, "--git-dir", gitDir, "config", "core.worktree", step.Value)
+				}
+				return nil
+			},
+		},
+	}...)
+
+	if plan.CreateConfig {
+		configPath := filepath.Join(dir, ".gitsej")
+		steps = append(steps, migrateStep{
+			name: "create-config",
+			apply: func(_ context.Context, _ *migrateJournal, _ *journalStep) error {
+				if err := os.WriteFile(configPath, []byte(gitsejConfigContent(plan.MainBranch, plan.MainWorktreeDir)), 0o644); err != nil {
+					return fmt.Errorf("write .gitsej: %w", err)
+				}
+				if plan.PostWorktreeAddHook == "" {
+					return nil
+				}
+				cfg, err := loadConfig(dir)
+				if err != nil {
+					return err
+				}
+				if err := cfg.Set("hook."+postWorktreeAddHook, plan.PostWorktreeAddHook); err != nil {
+					return err
+				}
+				return cfg.Save(dir)
+			},
+			rollback: func(_ context.Context, _ *migrateJournal, _ journalStep) error {
+				if err := os.Remove(configPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("remove .gitsej: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if len(plan.ConfigUpdates) > 0 {
+		configPath := filepath.Join(dir, ".gitsej")
+		steps = append(steps, migrateStep{
+			name: "update-config",
+			apply: func(_ context.Context, j *migrateJournal, step *journalStep) error {
+				content, err := os.ReadFile(configPath)
+				if err != nil {
+					return fmt.Errorf("read .gitsej: %w", err)
+				}
+				step.Value = string(content)
+				if err := j.save(); err != nil {
+					return err
+				}
+				cfg, err := loadConfig(dir)
+				if err != nil {
+					return err
+				}
+				for _, entry := range plan.ConfigUpdates {
+					if err := cfg.Set(entry.Key, entry.Value); err != nil {
+						return err
+					}
+				}
+				return cfg.Save(dir)
+			},
+			rollback: func(_ context.Context, _ *migrateJournal, step journalStep) error {
+				if !step.Done && step.Value == "" {
+					return nil
+				}
+				if err := os.WriteFile(configPath, []byte(step.Value), 0o644); err != nil {
+					return fmt.Errorf("restore .gitsej: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if plan.Sparse.Define {
+		key := sparseKeyPrefix + plan.Sparse.Name
+		steps = append(steps, migrateStep{
+			name: "save-sparse-profile",
+			apply: func(_ context.Context, _ *migrateJournal, step *journalStep) error {
+				cfg, err := loadConfig(dir)
+				if err != nil {
+					return err
+				}
+				step.Value, _ = cfg.Get(key)
+				return saveSparseProfile(dir, plan.Sparse)
+			},
+			rollback: func(_ context.Context, _ *migrateJournal, step journalStep) error {
+				cfg, err := loadConfig(dir)
+				if err != nil {
+					return err
+				}
+				if err := cfg.Set(key, step.Value); err != nil {
+					return err
+				}
+				return cfg.Save(dir)
+			},
+		})
+	}
+
+	if len(plan.RepairWorktrees) > 0 {
+		steps = append(steps, migrateStep{
+			name: "repair-worktrees",
+			apply: func(ctx context.Context, _ *migrateJournal, _ *journalStep) error {
+				for _, path := range plan.RepairWorktrees {
+					_ = runGit(ctx, "--git-dir", barePath, "worktree", "repair", path)
+				}
+				return nil
+			},
+		})
+	}
+
+	// Root entries are parked inside .bare instead of being deleted, so a
+	// rollback can put them back untouched. finish removes them.
+	for _, name := range plan.RemoveRootEntries {
+		from := filepath.Join(dir, name)
+		to := filepath.Join(stashPath, name)
+		steps = append(steps, migrateStep{
+			name: "remove-root-entry:" + name,
+			apply: func(_ context.Context, _ *migrateJournal, _ *journalStep) error {
+				if _, err := os.Lstat(from); errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				if err := os.MkdirAll(stashPath, 0o755); err != nil {
+					return fmt.Errorf("create %s: %w", stashPath, err)
+				}
+				if err := os.Rename(from, to); err != nil {
+					return fmt.Errorf("remove %s: %w", from, err)
+				}
+				return nil
+			},
+			rollback: func(_ context.Context, _ *migrateJournal, _ journalStep) error {
+				if _, err := os.Lstat(to); errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				if err := os.Rename(to, from); err != nil {
+					return fmt.Errorf("restore %s: %w", from, err)
+				}
+				return nil
+			},
+		})
+	}
+
+	steps = append(steps, migrateStep{
+		name: "add-main-worktree",
+		apply: func(ctx context.Context, _ *migrateJournal, _ *journalStep) error {
+			if worktreeRegistered(ctx, barePath, plan.MainWorktreePath) {
+				// Resuming: the worktree may exist without its sparse checkout.
+				return applySparseCheckout(ctx, plan.MainWorktreePath, plan.Sparse)
+			}
+			if err := runGit(ctx, worktreeAddArgs([]string{"--git-dir", barePath}, plan.Sparse, "--force", plan.MainWorktreePath, plan.MainBranch)...); err != nil {
+				return fmt.Errorf("create main worktree from %s: %w", plan.MainBranch, err)
+			}
+			if err := applySparseCheckout(ctx, plan.MainWorktreePath, plan.Sparse); err != nil {
+				return err
+			}
+			_ = runGit(
+				ctx,
+				"-C",
+				plan.MainWorktreePath,
+				"branch",
+				"--set-upstream-to",
+				"origin/"+plan.MainBranch,
+				plan.MainBranch,
+			)
+			return nil
+		},
+		rollback: func(ctx context.Context, _ *migrateJournal, _ journalStep) error {
+			if worktreeRegistered(ctx, barePath, plan.MainWorktreePath) {
+				if err := runGit(ctx, "--git-dir", barePath, "worktree", "remove", "--force", plan.MainWorktreePath); err != nil {
+					return err
+				}
+			}
+			if err := os.RemoveAll(plan.MainWorktreePath); err != nil {
+				return fmt.Errorf("remove %s: %w", plan.MainWorktreePath, err)
+			}
+			return runGit(ctx, "--git-dir", barePath, "worktree", "prune")
+		},
+	})
+
+	if plan.InitSubmodules {
+		steps = append(steps, migrateStep{
+			name: "init-submodules",
+			apply: func(ctx context.Context, _ *migrateJournal, _ *journalStep) error {
+				return initSubmodules(ctx, dir, plan.MainWorktreePath)
+			},
+		})
+	}
+
+	// Ignored files to keep were parked with the other root entries and are
+	// moved from there into the new main worktree.
+	for _, rel := range plan.KeepIgnored {
+		from := filepath.Join(stashPath, rel)
+		to := filepath.Join(plan.MainWorktreePath, rel)
+		steps = append(steps, migrateStep{
+			name: "keep-ignored:" + rel,
+			apply: func(_ context.Context, _ *migrateJournal, step *journalStep) error {
+				if !pathExists(from) || pathExists(to) {
+					return nil
+				}
+				if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+					return fmt.Errorf("create %s: %w", filepath.Dir(to), err)
+				}
+				if err := os.Rename(from, to); err != nil {
+					return fmt.Errorf("move ignored %s into main worktree: %w", rel, err)
+				}
+				step.Value = to
+				return nil
+			},
+			rollback: func(_ context.Context, _ *migrateJournal, step journalStep) error {
+				if step.Value == "" || !pathExists(to) || pathExists(from) {
+					return nil
+				}
+				if err := os.MkdirAll(filepath.Dir(from), 0o755); err != nil {
+					return fmt.Errorf("create %s: %w", filepath.Dir(from), err)
+				}
+				if err := os.Rename(to, from); err != nil {
+					return fmt.Errorf("restore ignored %s: %w", rel, err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if plan.CarryChanges {
+		steps = append(steps, migrateStep{
+			name: "apply-changes",
+			apply: func(ctx context.Context, j *migrateJournal, step *journalStep) error {
+				stash := j.step("stash-changes")
+				if stash == nil || stash.Value == "" {
+					return errors.New("apply uncommitted changes: missing stash")
+				}
+				conflicts, err := applyStash(ctx, plan.MainWorktreePath, stash.Value)
+				if err != nil {
+					return err
+				}
+				step.Paths = conflicts
+				if len(conflicts) == 0 {
+					return dropStash(ctx, plan.MainWorktreePath, stash.Value)
+				}
+				return nil
+			},
+		})
+	}
+
+	for _, move := range plan.WorktreeMoves {
+		steps = append(steps, migrateStep{
+			name: "move-worktree:" + move.From,
+			apply: func(ctx context.Context, _ *migrateJournal, _ *journalStep) error {
+				if !pathExists(move.From) && pathExists(move.To) {
+					return nil
+				}
+				if err := runGit(ctx, worktreeMoveArgs(barePath, move.Locked, move.From, move.To)...); err != nil {
+					return fmt.Errorf("move worktree %s to %s: %w", move.From, move.To, err)
+				}
+				return nil
+			},
+			rollback: func(ctx context.Context, _ *migrateJournal, _ journalStep) error {
+				if pathExists(move.From) || !pathExists(move.To) {
+					return nil
+				}
+				return runGit(ctx, worktreeMoveArgs(barePath, move.Locked, move.To, move.From)...)
+			},
+		})
+	}
+
+	// Pruning cannot be undone, so it runs last. Only the planned entries are
+	// removed; git worktree prune would also drop unrelated stale entries.
+	if len(plan.PruneWorktrees) > 0 {
+		steps = append(steps, migrateStep{
+			name: "prune-worktrees",
+			apply: func(_ context.Context, j *migrateJournal, step *journalStep) error {
+				for _, path := range plan.PruneWorktrees {
+					name, ok := worktreeAdminDir(barePath, path)
+					if !ok {
+						continue
+					}
+					if err := os.RemoveAll(filepath.Join(barePath, "worktrees", name)); err != nil {
+						return fmt.Errorf("prune missing worktree %s: %w", path, err)
+					}
+					step.Paths = append(step.Paths, path)
+					if err := j.save(); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	return steps
+}
+
+// applyStash applies a stash with its index in worktree. When the changes do
+// not apply cleanly they are merged with conflict markers and the conflicted
+// paths are returned; the stash is left in place for the caller to keep.
+func applyStash(ctx context.Context, worktree, stash string) ([]string, error) {
+	if err := runGit(ctx, "-C", worktree, "stash", "apply", "--index", stash); err == nil {
+		return nil, nil
+	}
+	applyErr := runGit(ctx, "-C", worktree, "stash", "apply", stash)
+
+	conflicts, err := unmergedPaths(ctx, worktree)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) == 0 && applyErr != nil {
+		return nil, fmt.Errorf("apply uncommitted changes in %s: %w", worktree, applyErr)
+	}
+	return conflicts, nil
+}
+
+// dropStash removes the stash entry for commit, if it is still listed.
+func dropStash(ctx context.Context, dir, commit string) error {
+	out, err := runGitOutput(ctx, "-C", dir, "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	for i, hash := range strings.Fields(out) {
+		if hash == commit {
+			return runGit(ctx, "-C", dir, "stash", "drop", fmt.Sprintf("stash@{%d}", i))
+		}
+	}
+	return nil
+}
+
+// worktreeMoveArgs builds a git worktree move. Locked worktrees need a double
+// --force; git keeps the lock and its reason on the moved worktree.
+func worktreeMoveArgs(gitDir string, locked bool, from, to string) []string {
+	args := []string{"--git-dir", gitDir, "worktree", "move"}
+	if locked {
+		args = append(args, "--force", "--force")
+	}
+	return append(args, from, to)
+}
+
+func worktreeRegistered(ctx context.Context, repoDir, path string) bool {
+	worktrees, err := listWorktrees(ctx, repoDir)
+	if err != nil {
+		return false
+	}
+	target := canonicalPath(path)
+	for _, wt := range worktrees {
+		if !wt.Bare && canonicalPath(wt.Path) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// worktreeAdminDir returns the name of the entry in gitDir/worktrees that
+// registers the worktree at path, which may no longer exist.
+func worktreeAdminDir(gitDir, path string) (string, bool) {
+	entries, err := os.ReadDir(filepath.Join(gitDir, "worktrees"))
+	if err != nil {
+		return "", false
+	}
+	target := canonicalPath(path)
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(gitDir, "worktrees", entry.Name(), "gitdir"))
+		if err != nil {
+			continue
+		}
+		if canonicalPath(filepath.Dir(strings.TrimSpace(string(content)))) == target {
+			return entry.Name(), true
+		}
+	}
+	return "", false
+}
+
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
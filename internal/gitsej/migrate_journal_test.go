@@ -0,0 +1,176 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateRollsBackWhenStepFails(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir, featureWorktree := newStandardCloneWithWorktree(t, ctx, "rollback")
+
+	_, err := Migrate(ctx, MigrateOptions{
+		Directory:      repoDir,
+		MainBranch:     "does-not-exist",
+		ForceMainClean: true,
+	})
+	if err == nil {
+		t.Fatalf("expected Migrate to fail for a missing main branch")
+	}
+	if !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rollback in error, got %v", err)
+	}
+
+	assertStandardClone(t, ctx, repoDir, featureWorktree)
+}
+
+func TestAbortMigrateRestoresInterruptedMigration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir, featureWorktree := newStandardCloneWithWorktree(t, ctx, "abort")
+	interruptMigrate(t, ctx, repoDir, "add-main-worktree")
+
+	if _, err := Migrate(ctx, MigrateOptions{Directory: repoDir}); err == nil || !strings.Contains(err.Error(), "--resume or --abort") {
+		t.Fatalf("expected interrupted migration error, got %v", err)
+	}
+
+	if err := AbortMigrate(ctx, repoDir); err != nil {
+		t.Fatalf("AbortMigrate: %v", err)
+	}
+	assertStandardClone(t, ctx, repoDir, featureWorktree)
+
+	if err := AbortMigrate(ctx, repoDir); !errors.Is(err, ErrNoMigrationInProgress) {
+		t.Fatalf("second AbortMigrate error = %v, want ErrNoMigrationInProgress", err)
+	}
+}
+
+func TestResumeMigrateCompletesInterruptedMigration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir, featureWorktree := newStandardCloneWithWorktree(t, ctx, "resume")
+	interruptMigrate(t, ctx, repoDir, "move-worktree:"+featureWorktree)
+
+	result, err := ResumeMigrate(ctx, repoDir, nil)
+	if err != nil {
+		t.Fatalf("ResumeMigrate: %v", err)
+	}
+	if len(result.MovedWorktrees) != 1 {
+		t.Fatalf("result.MovedWorktrees = %v, want one moved worktree", result.MovedWorktrees)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "main", "README.md")); err != nil {
+		t.Fatalf("expected main worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "resume-feature", "README.md")); err != nil {
+		t.Fatalf("expected moved feature worktree: %v", err)
+	}
+	for _, name := range []string{migrateJournalName, migrateStashName} {
+		if _, err := os.Stat(filepath.Join(repoDir, ".bare", name)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be removed, stat err=%v", name, err)
+		}
+	}
+}
+
+func newStandardCloneWithWorktree(t *testing.T, ctx context.Context, name string) (string, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	repoDir := filepath.Join(base, name)
+	featureWorktree := filepath.Join(base, name+"-feature")
+
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	if err := os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	runGitTest(t, ctx, "-C", repoDir, "add", "README.md")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "init")
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", "-b", "feature", featureWorktree)
+	if err := os.WriteFile(filepath.Join(repoDir, "notes.txt"), []byte("untracked\n"), 0o644); err != nil {
+		t.Fatalf("write untracked file: %v", err)
+	}
+
+	return canonicalPath(repoDir), canonicalPath(featureWorktree)
+}
+
+// interruptMigrate runs a migration up to, but not including, the named step
+// and leaves its journal behind, as if the process had been killed.
+func interruptMigrate(t *testing.T, ctx context.Context, repoDir, stopAt string) {
+	t.Helper()
+
+	plan, err := PlanMigrate(ctx, MigrateOptions{Directory: repoDir, ForceMainClean: true})
+	if err != nil {
+		t.Fatalf("PlanMigrate: %v", err)
+	}
+	steps := migrateSteps(plan)
+	stop := -1
+	for i, step := range steps {
+		if step.name == stopAt {
+			stop = i
+		}
+	}
+	if stop < 0 {
+		t.Fatalf("no migration step %q", stopAt)
+	}
+
+	j := &migrateJournal{Plan: plan}
+	if err := j.save(); err != nil {
+		t.Fatalf("save journal: %v", err)
+	}
+	if err := j.apply(ctx, steps[:stop]); err != nil {
+		t.Fatalf("apply steps: %v", err)
+	}
+}
+
+func assertStandardClone(t *testing.T, ctx context.Context, repoDir, featureWorktree string) {
+	t.Helper()
+
+	if info, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil || !info.IsDir() {
+		t.Fatalf("expected .git directory to be restored, err=%v", err)
+	}
+	for _, name := range []string{".bare", ".gitsej", "main"} {
+		if _, err := os.Stat(filepath.Join(repoDir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be gone, stat err=%v", name, err)
+		}
+	}
+	for _, name := range []string{"README.md", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(repoDir, name)); err != nil {
+			t.Fatalf("expected %s to be restored: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".git", migrateJournalName)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected journal to be removed, stat err=%v", err)
+	}
+
+	bare, err := runGitTestOutput(ctx, "-C", repoDir, "rev-parse", "--is-bare-repository")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	if strings.TrimSpace(bare) != "false" {
+		t.Fatalf("expected non-bare repository after rollback, got %q", strings.TrimSpace(bare))
+	}
+	status, err := runGitTestOutput(ctx, "-C", repoDir, "status", "--porcelain")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if strings.TrimSpace(status) != "?? notes.txt" {
+		t.Fatalf("unexpected status after rollback: %q", status)
+	}
+	branch, err := runGitTestOutput(ctx, "-C", featureWorktree, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("feature worktree is broken after rollback: %v", err)
+	}
+	if strings.TrimSpace(branch) != "feature" {
+		t.Fatalf("feature worktree HEAD = %q, want feature", strings.TrimSpace(branch))
+	}
+}
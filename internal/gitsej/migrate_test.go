@@ -141,6 +141,483 @@ func TestMigrateForceCleansDirtyMain(t *testing.T) {
 	}
 }
 
+func TestMigrateUsesMainWorktreeDir(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	repoDir := filepath.Join(base, "trunked")
+
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	runGitTest(t, ctx, "-C", repoDir, "commit", "--allow-empty", "-m", "init")
+
+	result, err := Migrate(ctx, MigrateOptions{
+		Directory:       repoDir,
+		MainWorktreeDir: "trunk",
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if got, want := result.CreatedMainWorktree, filepath.Join(result.Directory, "trunk"); got != want {
+		t.Fatalf("result.CreatedMainWorktree = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "trunk", ".git")); err != nil {
+		t.Fatalf("expected trunk worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "main")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("did not expect main/ worktree, stat err=%v", err)
+	}
+
+	cfgData, err := os.ReadFile(filepath.Join(repoDir, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if !strings.Contains(string(cfgData), "main_worktree=trunk\n") {
+		t.Fatalf("expected main_worktree=trunk in .gitsej, got:\n%s", string(cfgData))
+	}
+}
+
+func TestMigrateUpdatesExistingConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	runGitTest(t, ctx, "-C", repoDir, "commit", "--allow-empty", "-m", "init")
+	runGitTest(t, ctx, "-C", repoDir, "branch", "develop")
+	writeTestFile(t, filepath.Join(repoDir, ".gitsej"), "# mine\nlabel=api\nmain_worktree=main\nmain_branch=main\n")
+	writeTestFile(t, filepath.Join(repoDir, ".git", "info", "exclude"), ".gitsej\n")
+
+	result, err := Migrate(ctx, MigrateOptions{
+		Directory:       repoDir,
+		MainBranch:      "develop",
+		MainWorktreeDir: "trunk",
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if got, want := result.CreatedMainWorktree, filepath.Join(result.Directory, "trunk"); got != want {
+		t.Fatalf("result.CreatedMainWorktree = %q, want %q", got, want)
+	}
+
+	cfgData, err := os.ReadFile(filepath.Join(repoDir, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if got, want := string(cfgData), "# mine\nlabel=api\nmain_worktree=trunk\nmain_branch=develop\n"; got != want {
+		t.Fatalf(".gitsej = %q, want %q", got, want)
+	}
+}
+
+func TestMigrateDryRunReturnsPlanWithoutChanges(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	repoDir := filepath.Join(base, "planned")
+	featureWorktree := filepath.Join(base, "planned-feature")
+	nestedWorktree := filepath.Join(repoDir, "wt", "nested")
+
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	if err := os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".gitignore"), []byte("wt/\n"), 0o644); err != nil {
+		t.Fatalf("write .gitignore: %v", err)
+	}
+	runGitTest(t, ctx, "-C", repoDir, "add", "README.md", ".gitignore")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "init")
+	runGitTest(t, ctx, "-C", repoDir, "branch", "feature")
+	runGitTest(t, ctx, "-C", repoDir, "branch", "nested")
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", featureWorktree, "feature")
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", nestedWorktree, "nested")
+
+	dryRun, err := Migrate(ctx, MigrateOptions{Directory: repoDir, DryRun: true})
+	if err != nil {
+		t.Fatalf("Migrate(dry-run): %v", err)
+	}
+	if !dryRun.DryRun {
+		t.Fatalf("expected result.DryRun")
+	}
+
+	plan := dryRun.Plan
+	if got, want := strings.Join(plan.RemoveRootEntries, ","), ".gitignore,README.md"; got != want {
+		t.Fatalf("plan.RemoveRootEntries = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(plan.KeptRootEntries, ","), "wt"; got != want {
+		t.Fatalf("plan.KeptRootEntries = %q, want %q", got, want)
+	}
+	if !plan.CreateConfig {
+		t.Fatalf("expected plan.CreateConfig")
+	}
+	if len(plan.WorktreeMoves) != 1 || plan.WorktreeMoves[0].From != featureWorktree {
+		t.Fatalf("unexpected plan.WorktreeMoves: %+v", plan.WorktreeMoves)
+	}
+
+	if info, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil || !info.IsDir() {
+		t.Fatalf("expected .git directory to be untouched, err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".bare")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("did not expect .bare after dry run, stat err=%v", err)
+	}
+	if _, err := os.Stat(featureWorktree); err != nil {
+		t.Fatalf("expected feature worktree to stay in place: %v", err)
+	}
+
+	result, err := Migrate(ctx, MigrateOptions{Directory: repoDir})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if got, want := strings.Join(result.RemovedRootEntries, ","), strings.Join(plan.RemoveRootEntries, ","); got != want {
+		t.Fatalf("result.RemovedRootEntries = %q, want plan %q", got, want)
+	}
+	if len(result.MovedWorktrees) != 1 || result.MovedWorktrees[0] != plan.WorktreeMoves[0].To {
+		t.Fatalf("result.MovedWorktrees = %v, want %s", result.MovedWorktrees, plan.WorktreeMoves[0].To)
+	}
+	if _, err := os.Stat(filepath.Join(nestedWorktree, "README.md")); err != nil {
+		t.Fatalf("expected nested worktree to survive migration: %v", err)
+	}
+}
+
+func TestMigrateKeepsRootEntriesHoldingLinkedWorktrees(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	nestedWorktree := filepath.Join(repoDir, "wt", "nested")
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	writeTestFile(t, filepath.Join(repoDir, ".gitignore"), "wt/\n")
+	runGitTest(t, ctx, "-C", repoDir, "add", ".gitignore")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "init")
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", "-b", "nested", nestedWorktree)
+	writeTestFile(t, filepath.Join(repoDir, "wt", "notes.txt"), "kept with the worktree\n")
+
+	if _, err := Migrate(ctx, MigrateOptions{Directory: repoDir}); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "wt", "notes.txt")); err != nil {
+		t.Fatalf("expected wt/ to be kept as a whole: %v", err)
+	}
+	head, err := runGitTestOutput(ctx, "-C", nestedWorktree, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("nested worktree is broken after migration: %v", err)
+	}
+	if strings.TrimSpace(head) != "nested" {
+		t.Fatalf("nested worktree HEAD = %q, want nested", strings.TrimSpace(head))
+	}
+}
+
+func TestMigrateCarryChangesReappliesDirtyState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	repoDir := filepath.Join(base, "carry")
+
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	writeTestFile(t, filepath.Join(repoDir, "file.txt"), "hello\n")
+	writeTestFile(t, filepath.Join(repoDir, ".gitignore"), "*.log\n")
+	runGitTest(t, ctx, "-C", repoDir, "add", "file.txt", ".gitignore")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "init")
+
+	writeTestFile(t, filepath.Join(repoDir, "file.txt"), "changed\n")
+	writeTestFile(t, filepath.Join(repoDir, "staged.txt"), "staged\n")
+	runGitTest(t, ctx, "-C", repoDir, "add", "staged.txt")
+	writeTestFile(t, filepath.Join(repoDir, "untracked.txt"), "untracked\n")
+	writeTestFile(t, filepath.Join(repoDir, "debug.log"), "ignored\n")
+
+	result, err := Migrate(ctx, MigrateOptions{
+		Directory:    repoDir,
+		CarryChanges: true,
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if !result.CarriedChanges || len(result.CarryConflicts) != 0 {
+		t.Fatalf("unexpected carry result: carried=%v conflicts=%v", result.CarriedChanges, result.CarryConflicts)
+	}
+
+	mainDir := filepath.Join(repoDir, "main")
+	status, err := runGitTestOutput(ctx, "-C", mainDir, "status", "--porcelain")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if got, want := status, " M file.txt\nA  staged.txt\n?? untracked.txt\n"; got != want {
+		t.Fatalf("main worktree status = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(mainDir, "debug.log")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("did not expect ignored file to be carried, stat err=%v", err)
+	}
+
+	stashes, err := runGitTestOutput(ctx, "-C", mainDir, "stash", "list")
+	if err != nil {
+		t.Fatalf("stash list: %v", err)
+	}
+	if strings.TrimSpace(stashes) != "" {
+		t.Fatalf("expected carry stash to be dropped, got %q", stashes)
+	}
+}
+
+func TestMigrateCarryChangesReportsConflicts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	repoDir := filepath.Join(base, "conflict")
+
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	// A name with a space and non-ASCII characters must be reported intact.
+	name := "release notés.txt"
+	writeTestFile(t, filepath.Join(repoDir, name), "main\n")
+	runGitTest(t, ctx, "-C", repoDir, "add", name)
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "init")
+	runGitTest(t, ctx, "-C", repoDir, "checkout", "-b", "topic")
+	writeTestFile(t, filepath.Join(repoDir, name), "topic\n")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-am", "topic")
+	writeTestFile(t, filepath.Join(repoDir, name), "dirty\n")
+
+	result, err := Migrate(ctx, MigrateOptions{
+		Directory:    repoDir,
+		MainBranch:   "main",
+		CarryChanges: true,
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if got, want := strings.Join(result.CarryConflicts, ","), name; got != want {
+		t.Fatalf("result.CarryConflicts = %q, want %q", got, want)
+	}
+	if result.CarryStash == "" {
+		t.Fatalf("expected the stash to be kept when changes conflict")
+	}
+	if _, err := runGitTestOutput(ctx, "--git-dir", filepath.Join(repoDir, ".bare"), "cat-file", "-e", result.CarryStash); err != nil {
+		t.Fatalf("expected stash %s to exist: %v", result.CarryStash, err)
+	}
+}
+
+func TestMigrateKeepsMatchingIgnoredFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	repoDir := filepath.Join(base, "ignored")
+
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	writeTestFile(t, filepath.Join(repoDir, ".gitignore"), ".env\nnode_modules/\n*.log\n")
+	runGitTest(t, ctx, "-C", repoDir, "add", ".gitignore")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "init")
+
+	writeTestFile(t, filepath.Join(repoDir, ".env"), "SECRET=1\n")
+	writeTestFile(t, filepath.Join(repoDir, "debug.log"), "noise\n")
+	for _, dir := range []string{"node_modules/pkg", "web/node_modules/pkg"} {
+		if err := os.MkdirAll(filepath.Join(repoDir, dir), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+		writeTestFile(t, filepath.Join(repoDir, dir, "index.js"), "module.exports = {}\n")
+	}
+
+	result, err := Migrate(ctx, MigrateOptions{
+		Directory:   repoDir,
+		KeepIgnored: []string{".env", "node_modules"},
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	want := []string{".env", "node_modules", filepath.Join("web", "node_modules")}
+	if got := strings.Join(result.CarriedIgnored, ","); got != strings.Join(want, ",") {
+		t.Fatalf("result.CarriedIgnored = %q, want %q", got, strings.Join(want, ","))
+	}
+
+	mainDir := filepath.Join(repoDir, "main")
+	for _, path := range []string{".env", "node_modules/pkg/index.js", "web/node_modules/pkg/index.js"} {
+		if _, err := os.Stat(filepath.Join(mainDir, path)); err != nil {
+			t.Fatalf("expected %s in main worktree: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(mainDir, "debug.log")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("did not expect unmatched ignored file to be kept, stat err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".bare", migrateStashName)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected parked root entries to be removed, stat err=%v", err)
+	}
+}
+
+func TestMigrateKeepsSubmodulesLocal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	libDir := filepath.Join(base, "lib")
+	repoDir := filepath.Join(base, "super")
+
+	runGitTest(t, ctx, "init", "-b", "main", libDir)
+	writeTestFile(t, filepath.Join(libDir, "lib.txt"), "lib\n")
+	runGitTest(t, ctx, "-C", libDir, "add", "lib.txt")
+	runGitTest(t, ctx, "-C", libDir, "commit", "-m", "lib")
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	runGitTest(t, ctx, "-C", repoDir, "-c", "protocol.file.allow=always", "submodule", "add", libDir, "vendor/lib")
+	runGitTest(t, ctx, "-C", repoDir, "commit", "-m", "add submodule")
+	runGitTest(t, ctx, "-C", repoDir, "branch", "feature")
+
+	wantCommit, err := runGitTestOutput(ctx, "-C", filepath.Join(repoDir, "vendor", "lib"), "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse submodule: %v", err)
+	}
+
+	// Without the upstream, the submodule can only come from .bare/modules.
+	if err := os.RemoveAll(libDir); err != nil {
+		t.Fatalf("remove submodule upstream: %v", err)
+	}
+
+	result, err := Migrate(ctx, MigrateOptions{Directory: repoDir})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if !result.Plan.InitSubmodules {
+		t.Fatalf("expected plan to initialize submodules")
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".bare", "modules", "vendor", "lib")); err != nil {
+		t.Fatalf("expected module repository under .bare/modules: %v", err)
+	}
+
+	added, err := AddWorktree(ctx, AddWorktreeOptions{Directory: repoDir, Branch: "feature"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	for _, worktree := range []string{result.CreatedMainWorktree, added.Path} {
+		subDir := filepath.Join(worktree, "vendor", "lib")
+		if _, err := os.Stat(filepath.Join(subDir, "lib.txt")); err != nil {
+			t.Fatalf("expected submodule checkout in %s: %v", worktree, err)
+		}
+		gotCommit, err := runGitTestOutput(ctx, "-C", subDir, "rev-parse", "HEAD")
+		if err != nil {
+			t.Fatalf("rev-parse submodule in %s: %v", worktree, err)
+		}
+		if gotCommit != wantCommit {
+			t.Fatalf("submodule HEAD in %s = %q, want %q", worktree, gotCommit, wantCommit)
+		}
+		url, err := runGitTestOutput(ctx, "-C", subDir, "remote", "get-url", "origin")
+		if err != nil {
+			t.Fatalf("submodule origin in %s: %v", worktree, err)
+		}
+		if strings.TrimSpace(url) != libDir {
+			t.Fatalf("submodule origin in %s = %q, want %q", worktree, strings.TrimSpace(url), libDir)
+		}
+	}
+}
+
+func TestMigrateReportsLockedAndMissingWorktrees(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := canonicalPath(t.TempDir())
+	repoDir := filepath.Join(base, "locks")
+	lockedWorktree := filepath.Join(base, "locks-usb")
+	unmountedWorktree := filepath.Join(base, "locks-unmounted")
+	staleWorktree := filepath.Join(base, "locks-stale")
+
+	runGitTest(t, ctx, "init", "-b", "main", repoDir)
+	runGitTest(t, ctx, "-C", repoDir, "commit", "--allow-empty", "-m", "init")
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", "-b", "usb", lockedWorktree)
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "lock", "--reason", "on usb drive", lockedWorktree)
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", "-b", "unmounted", unmountedWorktree)
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "lock", unmountedWorktree)
+	runGitTest(t, ctx, "-C", repoDir, "worktree", "add", "-b", "stale", staleWorktree)
+	for _, path := range []string{unmountedWorktree, staleWorktree} {
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("remove %s: %v", path, err)
+		}
+	}
+	// An admin entry without a gitdir file is not a planned prune and must
+	// survive, although git worktree prune would remove it.
+	orphan := filepath.Join(repoDir, ".git", "worktrees", "orphan")
+	if err := os.MkdirAll(orphan, 0o755); err != nil {
+		t.Fatalf("create %s: %v", orphan, err)
+	}
+
+	result, err := Migrate(ctx, MigrateOptions{
+		Directory:    repoDir,
+		PruneMissing: true,
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	movedLocked := filepath.Join(repoDir, "locks-usb")
+	wantLocks := []WorktreeLock{
+		{Path: unmountedWorktree},
+		{Path: movedLocked, Reason: "on usb drive"},
+	}
+	if len(result.LockedWorktrees) != len(wantLocks) {
+		t.Fatalf("result.LockedWorktrees = %+v, want %+v", result.LockedWorktrees, wantLocks)
+	}
+	for i, want := range wantLocks {
+		if result.LockedWorktrees[i] != want {
+			t.Fatalf("result.LockedWorktrees[%d] = %+v, want %+v", i, result.LockedWorktrees[i], want)
+		}
+	}
+	if got, want := strings.Join(result.MissingWorktrees, ","), staleWorktree+","+unmountedWorktree; got != want {
+		t.Fatalf("result.MissingWorktrees = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(result.PrunedWorktrees, ","), staleWorktree; got != want {
+		t.Fatalf("result.PrunedWorktrees = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".bare", "worktrees", "orphan")); err != nil {
+		t.Fatalf("expected unplanned admin entry to be kept: %v", err)
+	}
+
+	worktrees, err := listWorktrees(ctx, filepath.Join(repoDir, ".bare"))
+	if err != nil {
+		t.Fatalf("listWorktrees: %v", err)
+	}
+	byPath := make(map[string]worktreeInfo, len(worktrees))
+	for _, wt := range worktrees {
+		byPath[canonicalPath(wt.Path)] = wt
+	}
+	if wt, ok := byPath[movedLocked]; !ok || !wt.Locked || wt.LockReason != "on usb drive" {
+		t.Fatalf("expected moved worktree to keep its lock, got %+v (found=%v)", wt, ok)
+	}
+	if _, ok := byPath[unmountedWorktree]; !ok {
+		t.Fatalf("expected locked missing worktree to stay registered")
+	}
+	if _, ok := byPath[staleWorktree]; ok {
+		t.Fatalf("expected stale worktree entry to be pruned")
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
 func runGitTest(t *testing.T, ctx context.Context, args ...string) {
 	t.Helper()
 	if _, err := runGitTestOutput(ctx, args...); err != nil {
@@ -0,0 +1,194 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type RebaseStatus string
+
+const (
+	RebaseRebased  RebaseStatus = "rebased"
+	RebaseUpToDate RebaseStatus = "up-to-date"
+	RebaseConflict RebaseStatus = "conflict"
+	RebaseDirty    RebaseStatus = "skipped-dirty"
+	RebaseSkipped  RebaseStatus = "skipped"
+	RebaseFailed   RebaseStatus = "failed"
+)
+
+type RebaseOptions struct {
+	Directory string
+	// Worktree selects one worktree like gitsej rm does; All selects every
+	// worktree with a branch other than main_branch.
+	Worktree  string
+	All       bool
+	AutoStash bool
+}
+
+type RebaseResult struct {
+	Root      string
+	Onto      string
+	Worktrees []WorktreeRebase
+}
+
+// WorktreeRebase is the outcome of rebasing one worktree. Commits is how far
+// the branch was behind Onto. A conflicting worktree is left mid-rebase with
+// its unmerged paths in Conflicts; Reason explains skipped worktrees.
+type WorktreeRebase struct {
+	Path      string
+	Branch    string
+	Status    RebaseStatus
+	Commits   int
+	Conflicts []string
+	Reason    string
+	Err       error
+}
+
+// Count returns the number of worktrees with the given status.
+func (r RebaseResult) Count(status RebaseStatus) int {
+	n := 0
+	for _, wt := range r.Worktrees {
+		if wt.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Rebase fetches origin and rebases feature worktrees onto
+// origin/<main_branch>. Each worktree stops at its first conflict and is left
+// in rebase state for the user to resolve; the others carry on. Dirty
+// worktrees are skipped unless opts.AutoStash is set.
+func Rebase(ctx context.Context, opts RebaseOptions) (RebaseResult, error) {
+	name := strings.TrimSpace(opts.Worktree)
+	switch {
+	case name == "" && !opts.All:
+		return RebaseResult{}, errors.New("worktree or --all is required")
+	case name != "" && opts.All:
+		return RebaseResult{}, errors.New("worktree and --all are mutually exclusive")
+	}
+
+	root, err := ResolveRoot(ctx, opts.Directory)
+	if err != nil {
+		return RebaseResult{}, err
+	}
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return RebaseResult{}, err
+	}
+	lock, err := lockRoot(ctx, root, "rebase")
+	if err != nil {
+		return RebaseResult{}, err
+	}
+	defer lock.Unlock()
+	mainCanonical := canonicalPath(cfg.MainWorktreePath(root))
+
+	var targets []worktreeInfo
+	if opts.All {
+		worktrees, err := listWorktrees(ctx, root)
+		if err != nil {
+			return RebaseResult{}, err
+		}
+		for _, wt := range worktrees {
+			if !wt.Bare {
+				targets = append(targets, wt)
+			}
+		}
+	} else {
+		wt, err := findWorktree(ctx, root, name)
+		if err != nil {
+			return RebaseResult{}, err
+		}
+		targets = append(targets, wt)
+	}
+
+	fetchArgs := append([]string{"-C", root, "fetch", "--prune"}, cloneFetchArgs(cfg)...)
+	if err := runGit(ctx, append(fetchArgs, "origin")...); err != nil {
+		return RebaseResult{}, fmt.Errorf("fetch origin: %w", err)
+	}
+	onto := "origin/" + cfg.MainBranch
+	if !gitRefExists(ctx, root, "refs/remotes/"+onto) {
+		return RebaseResult{}, fmt.Errorf("%s does not exist; check main_branch in .gitsej", onto)
+	}
+
+	result := RebaseResult{Root: root, Onto: onto}
+	for _, wt := range targets {
+		report := WorktreeRebase{Path: wt.Path, Branch: strings.TrimPrefix(wt.Branch, "refs/heads/")}
+		switch {
+		case wt.Prunable:
+			report.Status, report.Reason = RebaseSkipped, "worktree directory is missing"
+		case rebaseInProgress(ctx, wt.Path):
+			// git lists a worktree stopped mid-rebase as detached.
+			report.Status, report.Reason = RebaseSkipped, "rebase already in progress; run git rebase --continue or --abort"
+		case wt.Detached || wt.Branch == "":
+			report.Status, report.Reason = RebaseSkipped, "detached HEAD"
+		case canonicalPath(wt.Path) == mainCanonical || report.Branch == cfg.MainBranch:
+			report.Status, report.Reason = RebaseSkipped, "main branch"
+		default:
+			rebaseWorktree(ctx, &report, onto, opts.AutoStash)
+		}
+		result.Worktrees = append(result.Worktrees, report)
+	}
+	return result, nil
+}
+
+func rebaseWorktree(ctx context.Context, report *WorktreeRebase, onto string, autoStash bool) {
+	if _, err := os.Stat(report.Path); err != nil {
+		report.Status, report.Reason = RebaseSkipped, "worktree directory is missing"
+		return
+	}
+	out, err := runGitOutput(ctx, "-C", report.Path, "rev-list", "--count", "HEAD.."+onto)
+	if err != nil {
+		report.Status, report.Err = RebaseFailed, err
+		return
+	}
+	report.Commits, _ = strconv.Atoi(strings.TrimSpace(out))
+	if report.Commits == 0 {
+		report.Status = RebaseUpToDate
+		return
+	}
+
+	dirty, err := isWorktreeDirty(ctx, report.Path)
+	if err != nil {
+		report.Status, report.Err = RebaseFailed, err
+		return
+	}
+	if dirty && !autoStash {
+		report.Status, report.Reason = RebaseDirty, "uncommitted changes (use --autostash)"
+		return
+	}
+
+	args := []string{"-C", report.Path, "rebase"}
+	if autoStash {
+		args = append(args, "--autostash")
+	}
+	if err := runGit(ctx, append(args, onto)...); err != nil {
+		conflicts, _ := unmergedPaths(ctx, report.Path)
+		if len(conflicts) > 0 && rebaseInProgress(ctx, report.Path) {
+			report.Status = RebaseConflict
+			report.Conflicts = conflicts
+			return
+		}
+		report.Status, report.Err = RebaseFailed, err
+		return
+	}
+	report.Status = RebaseRebased
+}
+
+// rebaseInProgress reports whether the worktree at path is stopped mid-rebase.
+func rebaseInProgress(ctx context.Context, path string) bool {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		out, err := runGitOutput(ctx, "-C", path, "rev-parse", "--path-format=absolute", "--git-path", dir)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(strings.TrimSpace(out)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRebaseAllWorktrees(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	origin := filepath.Join(filepath.Dir(root), "origin")
+	// Rebase commits as the user; the tests have no global identity.
+	runGitTest(t, ctx, "-C", root, "config", "user.name", "gitsej-test")
+	runGitTest(t, ctx, "-C", root, "config", "user.email", "gitsej-test@example.invalid")
+
+	for _, branch := range []string{"main", "clean", "conflict", "dirty"} {
+		if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: branch}); err != nil {
+			t.Fatalf("AddWorktree(%s): %v", branch, err)
+		}
+	}
+	commitTestFile(t, ctx, filepath.Join(root, "clean"), "clean.txt", "clean\n")
+	commitTestFile(t, ctx, filepath.Join(root, "conflict"), "README.md", "feature\n")
+	commitTestFile(t, ctx, filepath.Join(root, "dirty"), "dirty.txt", "dirty\n")
+	writeTestFile(t, filepath.Join(root, "dirty", "dirty.txt"), "uncommitted\n")
+	commitTestFile(t, ctx, origin, "README.md", "upstream\n")
+
+	result, err := Rebase(ctx, RebaseOptions{Directory: root, All: true})
+	if err != nil {
+		t.Fatalf("Rebase: %v", err)
+	}
+	if result.Onto != "origin/main" {
+		t.Fatalf("Onto = %q, want origin/main", result.Onto)
+	}
+
+	got := make(map[string]WorktreeRebase, len(result.Worktrees))
+	for _, wt := range result.Worktrees {
+		got[filepath.Base(wt.Path)] = wt
+	}
+	want := map[string]RebaseStatus{
+		"main":     RebaseSkipped,
+		"clean":    RebaseRebased,
+		"conflict": RebaseConflict,
+		"dirty":    RebaseDirty,
+	}
+	for name, status := range want {
+		if got[name].Status != status {
+			t.Fatalf("%s status = %s, want %s (%+v)", name, got[name].Status, status, got[name])
+		}
+	}
+	if strings.Join(got["conflict"].Conflicts, ",") != "README.md" {
+		t.Fatalf("conflicts = %v, want [README.md]", got["conflict"].Conflicts)
+	}
+	if !rebaseInProgress(ctx, filepath.Join(root, "conflict")) {
+		t.Fatal("expected the conflicting worktree to be left mid-rebase")
+	}
+	if err := runGit(ctx, "-C", filepath.Join(root, "clean"), "merge-base", "--is-ancestor", "origin/main", "HEAD"); err != nil {
+		t.Fatalf("clean was not rebased onto origin/main: %v", err)
+	}
+
+	stashed, err := Rebase(ctx, RebaseOptions{Directory: root, Worktree: "dirty", AutoStash: true})
+	if err != nil {
+		t.Fatalf("Rebase(autostash): %v", err)
+	}
+	if len(stashed.Worktrees) != 1 || stashed.Worktrees[0].Status != RebaseRebased {
+		t.Fatalf("autostash rebase = %+v, want one rebased worktree", stashed.Worktrees)
+	}
+	content, err := os.ReadFile(filepath.Join(root, "dirty", "dirty.txt"))
+	if err != nil || string(content) != "uncommitted\n" {
+		t.Fatalf("dirty.txt = %q, %v; want the uncommitted edit back", content, err)
+	}
+
+	again, err := Rebase(ctx, RebaseOptions{Directory: root, Worktree: "conflict"})
+	if err != nil {
+		t.Fatalf("Rebase(conflict): %v", err)
+	}
+	if again.Worktrees[0].Status != RebaseSkipped || !strings.Contains(again.Worktrees[0].Reason, "in progress") {
+		t.Fatalf("second rebase of conflict = %+v, want skipped in progress", again.Worktrees[0])
+	}
+
+	if _, err := Rebase(ctx, RebaseOptions{Directory: root}); err == nil {
+		t.Fatal("expected an error without a worktree or --all")
+	}
+}
+
+func TestRebaseReportsConflictPathsIntact(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	origin := filepath.Join(filepath.Dir(root), "origin")
+	runGitTest(t, ctx, "-C", root, "config", "user.name", "gitsej-test")
+	runGitTest(t, ctx, "-C", root, "config", "user.email", "gitsej-test@example.invalid")
+
+	name := "release notés.txt"
+	commitTestFile(t, ctx, origin, name, "base\n")
+	runGitTest(t, ctx, "-C", root, "fetch", "origin")
+	feature, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "feature"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	commitTestFile(t, ctx, feature.Path, name, "feature\n")
+	commitTestFile(t, ctx, origin, name, "upstream\n")
+
+	result, err := Rebase(ctx, RebaseOptions{Directory: root, Worktree: "feature"})
+	if err != nil {
+		t.Fatalf("Rebase: %v", err)
+	}
+	if got := result.Worktrees[0]; got.Status != RebaseConflict || strings.Join(got.Conflicts, ",") != name {
+		t.Fatalf("rebase = %+v, want a conflict in %q", got, name)
+	}
+}
+
+func commitTestFile(t *testing.T, ctx context.Context, dir, name, content string) {
+	t.Helper()
+
+	writeTestFile(t, filepath.Join(dir, name), content)
+	runGitTest(t, ctx, "-C", dir, "add", name)
+	runGitTest(t, ctx, "-C", dir, "commit", "-m", "update "+name)
+}
@@ -0,0 +1,167 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type RemoveWorktreeOptions struct {
+	Directory    string
+	Worktree     string
+	Force        bool
+	DeleteBranch bool
+}
+
+type RemoveWorktreeResult struct {
+	Root          string
+	Path          string
+	Branch        string
+	DeletedBranch bool
+}
+
+type DirtyWorktreeError struct {
+	Path string
+}
+
+func (e *DirtyWorktreeError) Error() string {
+	return fmt.Sprintf("worktree has uncommitted changes: %s", e.Path)
+}
+
+func RemoveWorktree(ctx context.Context, opts RemoveWorktreeOptions) (RemoveWorktreeResult, error) {
+	name := strings.TrimSpace(opts.Worktree)
+	if name == "" {
+		return RemoveWorktreeResult{}, errors.New("worktree is required")
+	}
+
+	root, err := ResolveRoot(ctx, opts.Directory)
+	if err != nil {
+		return RemoveWorktreeResult{}, err
+	}
+	lock, err := lockRoot(ctx, root, "remove")
+	if err != nil {
+		return RemoveWorktreeResult{}, err
+	}
+	defer lock.Unlock()
+
+	worktree, err := findWorktree(ctx, root, name)
+	if err != nil {
+		return RemoveWorktreeResult{}, err
+	}
+	worktreePath := worktree.Path
+	branch := worktree.Branch
+
+	mainWorktreePath, err := configuredMainWorktree(root)
+	if err != nil {
+		return RemoveWorktreeResult{}, err
+	}
+	if canonicalPath(worktreePath) == canonicalPath(mainWorktreePath) {
+		return RemoveWorktreeResult{}, fmt.Errorf("refusing to remove main worktree: %s", worktreePath)
+	}
+
+	dirty, err := isWorktreeDirty(ctx, worktreePath)
+	if err != nil {
+		return RemoveWorktreeResult{}, err
+	}
+	if dirty && !opts.Force {
+		return RemoveWorktreeResult{}, &DirtyWorktreeError{Path: worktreePath}
+	}
+	if worktree.Locked && !opts.Force {
+		msg := fmt.Sprintf("worktree %s is locked", worktreePath)
+		if worktree.LockReason != "" {
+			msg += " (" + worktree.LockReason + ")"
+		}
+		return RemoveWorktreeResult{}, fmt.Errorf("%s; run git worktree unlock or use --force", msg)
+	}
+
+	if opts.DeleteBranch && branch != "" {
+		if err := ensureBranchMerged(ctx, root, branch); err != nil {
+			return RemoveWorktreeResult{}, err
+		}
+	}
+
+	// git only removes a locked worktree when --force is given twice.
+	args := []string{"-C", root, "worktree", "remove"}
+	if opts.Force {
+		args = append(args, "--force")
+		if worktree.Locked {
+			args = append(args, "--force")
+		}
+	}
+	args = append(args, worktreePath)
+	if err := runGit(ctx, args...); err != nil {
+		return RemoveWorktreeResult{}, fmt.Errorf("remove worktree %s: %w", worktreePath, err)
+	}
+
+	result := RemoveWorktreeResult{
+		Root:   root,
+		Path:   worktreePath,
+		Branch: branch,
+	}
+
+	if opts.DeleteBranch && branch != "" {
+		if err := runGit(ctx, "-C", root, "branch", "-D", branch); err != nil {
+			return result, fmt.Errorf("delete branch %s: %w", branch, err)
+		}
+		result.DeletedBranch = true
+	}
+
+	return result, nil
+}
+
+// findWorktree resolves name to a worktree of root. name may be a path
+// relative to root, a path relative to the current directory, or the branch
+// checked out in the worktree.
+func findWorktree(ctx context.Context, root, name string) (worktreeInfo, error) {
+	worktrees, err := listWorktrees(ctx, root)
+	if err != nil {
+		return worktreeInfo{}, err
+	}
+
+	candidates := []string{filepath.Join(root, name)}
+	if abs, err := filepath.Abs(name); err == nil {
+		candidates = append(candidates, abs)
+	}
+	for _, candidate := range candidates {
+		canonical := canonicalPath(candidate)
+		for _, wt := range worktrees {
+			if !wt.Bare && canonicalPath(wt.Path) == canonical {
+				return wt, nil
+			}
+		}
+	}
+
+	for _, wt := range worktrees {
+		if !wt.Bare && wt.Branch == name {
+			return wt, nil
+		}
+	}
+
+	return worktreeInfo{}, fmt.Errorf("worktree not found: %s", name)
+}
+
+func ensureBranchMerged(ctx context.Context, root, branch string) error {
+	mainBranch, err := configuredMainBranch(root)
+	if err != nil {
+		return err
+	}
+	if branch == mainBranch {
+		return fmt.Errorf("refusing to delete main branch: %s", branch)
+	}
+
+	targets := make([]string, 0, 2)
+	if gitRefExists(ctx, root, "refs/heads/"+mainBranch) {
+		targets = append(targets, mainBranch)
+	}
+	if gitRefExists(ctx, root, "refs/remotes/origin/"+mainBranch) {
+		targets = append(targets, "origin/"+mainBranch)
+	}
+	for _, target := range targets {
+		if runGit(ctx, "-C", root, "merge-base", "--is-ancestor", branch, target) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("branch %s is not fully merged into %s", branch, mainBranch)
+}
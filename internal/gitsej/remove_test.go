@@ -0,0 +1,151 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveWorktreeDeletesMergedBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	added, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "merged"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	result, err := RemoveWorktree(ctx, RemoveWorktreeOptions{
+		Directory:    root,
+		Worktree:     "merged",
+		DeleteBranch: true,
+	})
+	if err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	if !result.DeletedBranch {
+		t.Fatalf("expected branch deletion")
+	}
+	if _, err := os.Stat(added.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected worktree directory removed, stat err=%v", err)
+	}
+	if gitRefExists(ctx, root, "refs/heads/merged") {
+		t.Fatalf("expected branch merged to be deleted")
+	}
+}
+
+func TestRemoveWorktreeKeepsUnmergedBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	added, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "unmerged"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	runGitTest(t, ctx, "-C", added.Path, "commit", "--allow-empty", "-m", "wip")
+
+	if _, err := RemoveWorktree(ctx, RemoveWorktreeOptions{
+		Directory:    root,
+		Worktree:     added.Path,
+		DeleteBranch: true,
+	}); err == nil {
+		t.Fatalf("expected error for unmerged branch")
+	}
+	if _, err := os.Stat(added.Path); err != nil {
+		t.Fatalf("expected worktree kept when branch is unmerged: %v", err)
+	}
+
+	result, err := RemoveWorktree(ctx, RemoveWorktreeOptions{Directory: root, Worktree: "unmerged"})
+	if err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	if result.DeletedBranch || !gitRefExists(ctx, root, "refs/heads/unmerged") {
+		t.Fatalf("expected branch to be kept without --delete-branch")
+	}
+}
+
+func TestRemoveWorktreeRequiresForceWhenDirty(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	added, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "dirty"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(added.Path, "scratch.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	_, err = RemoveWorktree(ctx, RemoveWorktreeOptions{Directory: root, Worktree: "dirty"})
+	var dirtyErr *DirtyWorktreeError
+	if !errors.As(err, &dirtyErr) {
+		t.Fatalf("expected DirtyWorktreeError, got %T (%v)", err, err)
+	}
+
+	if _, err := RemoveWorktree(ctx, RemoveWorktreeOptions{
+		Directory: root,
+		Worktree:  "dirty",
+		Force:     true,
+	}); err != nil {
+		t.Fatalf("RemoveWorktree(force): %v", err)
+	}
+	if _, err := os.Stat(added.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected dirty worktree removed with force, stat err=%v", err)
+	}
+}
+
+func TestRemoveWorktreeRefusesMainWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	if _, err := RemoveWorktree(ctx, RemoveWorktreeOptions{
+		Directory: root,
+		Worktree:  "main",
+		Force:     true,
+	}); err == nil {
+		t.Fatalf("expected error when removing main worktree")
+	}
+}
+
+func TestRemoveWorktreeRequiresForceWhenLocked(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	added, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"})
+	if err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	runGitTest(t, ctx, "-C", root, "worktree", "lock", "--reason", "on usb drive", added.Path)
+
+	_, err = RemoveWorktree(ctx, RemoveWorktreeOptions{Directory: root, Worktree: "develop"})
+	if err == nil || !strings.Contains(err.Error(), "is locked (on usb drive); run git worktree unlock") {
+		t.Fatalf("RemoveWorktree error = %v, want locked error", err)
+	}
+	if _, err := os.Stat(added.Path); err != nil {
+		t.Fatalf("expected locked worktree to be kept: %v", err)
+	}
+
+	if _, err := RemoveWorktree(ctx, RemoveWorktreeOptions{Directory: root, Worktree: "develop", Force: true}); err != nil {
+		t.Fatalf("RemoveWorktree(force): %v", err)
+	}
+	if _, err := os.Stat(added.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected worktree directory removed, stat err=%v", err)
+	}
+}
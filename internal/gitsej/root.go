@@ -0,0 +1,89 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveRoot returns the gitsej root directory containing dir. dir may be the
+// root itself or any path inside one of its worktrees.
+func ResolveRoot(ctx context.Context, dir string) (string, error) {
+	targetDir := strings.TrimSpace(dir)
+	if targetDir == "" {
+		targetDir = "."
+	}
+
+	absTarget, err := filepath.Abs(targetDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve path %s: %w", targetDir, err)
+	}
+	if info, err := os.Stat(absTarget); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("directory does not exist: %s", absTarget)
+		}
+		return "", fmt.Errorf("check directory %s: %w", absTarget, err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", absTarget)
+	}
+
+	commonDir, err := runGitOutput(ctx, "-C", absTarget, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("not inside a gitsej repo: %s", absTarget)
+	}
+	commonDir = filepath.Clean(strings.TrimSpace(commonDir))
+	if filepath.Base(commonDir) != ".bare" {
+		return "", fmt.Errorf("not inside a gitsej repo: %s", absTarget)
+	}
+
+	root := filepath.Dir(commonDir)
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		return "", fmt.Errorf("not inside a gitsej repo: %s", absTarget)
+	}
+	return root, nil
+}
+
+func gitRefExists(ctx context.Context, dir, ref string) bool {
+	return runGit(ctx, "-C", dir, "show-ref", "--verify", "--quiet", ref) == nil
+}
+
+// configuredMainWorktree returns the absolute path of the main worktree named
+// by main_worktree in .gitsej.
+func configuredMainWorktree(root string) (string, error) {
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return "", err
+	}
+	return cfg.MainWorktreePath(root), nil
+}
+
+func configuredMainBranch(root string) (string, error) {
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return "", err
+	}
+	return cfg.MainBranch, nil
+}
+
+// IsRoot reports whether dir looks like a gitsej root: a .bare directory next
+// to a .git entry. When requireMarker is set a .gitsej file must exist too.
+func IsRoot(dir string, requireMarker bool) bool {
+	if strings.TrimSpace(dir) == "" {
+		return false
+	}
+	if info, err := os.Stat(filepath.Join(dir, ".bare")); err != nil || !info.IsDir() {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return false
+	}
+	if requireMarker {
+		if info, err := os.Stat(filepath.Join(dir, ".gitsej")); err != nil || info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package gitsej
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// sparseKeyPrefix starts the .gitsej keys that define sparse-checkout
+// profiles, e.g. sparse.backend=services/api,libs/go.
+const sparseKeyPrefix = "sparse."
+
+// sparseProfileName returns the profile a sparse.<name> key defines.
+func sparseProfileName(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, sparseKeyPrefix)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", false
+	}
+	return name, true
+}
+
+// parseSparseDirs parses a comma-separated list of repository directories for
+// a cone-mode profile. Surrounding slashes are dropped.
+func parseSparseDirs(value string) ([]string, error) {
+	var dirs []string
+	for _, dir := range strings.Split(value, ",") {
+		dir = strings.Trim(strings.TrimSpace(dir), "/")
+		if dir == "" {
+			continue
+		}
+		cleaned := path.Clean(dir)
+		if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("directory %q must be inside the repository", dir)
+		}
+		if !slices.Contains(dirs, cleaned) {
+			dirs = append(dirs, cleaned)
+		}
+	}
+	return dirs, nil
+}
+
+// SparseProfile is a named sparse-checkout profile resolved for a new
+// worktree. Define is set when the profile was given inline and still has to
+// be written to .gitsej.
+type SparseProfile struct {
+	Name   string
+	Dirs   []string
+	Define bool
+}
+
+// resolveSparseProfile resolves a --sparse value against cfg: either the name
+// of a sparse.<name> profile or an inline <name>=<dir>,<dir> definition.
+// An empty spec resolves to no profile.
+func resolveSparseProfile(cfg Config, spec string) (SparseProfile, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return SparseProfile{}, nil
+	}
+
+	name, value, inline := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if _, ok := sparseProfileName(sparseKeyPrefix + name); !ok {
+		return SparseProfile{}, fmt.Errorf("invalid sparse profile name %q", name)
+	}
+	if !inline {
+		dirs, ok := cfg.SparseProfiles[name]
+		if !ok {
+			return SparseProfile{}, fmt.Errorf("unknown sparse profile %q: set %s%s in .gitsej or pass %s=<dir>,<dir>", name, sparseKeyPrefix, name, name)
+		}
+		return SparseProfile{Name: name, Dirs: dirs}, nil
+	}
+
+	dirs, err := parseSparseDirs(value)
+	if err != nil {
+		return SparseProfile{}, fmt.Errorf("invalid sparse profile %s: %w", name, err)
+	}
+	if len(dirs) == 0 {
+		return SparseProfile{}, fmt.Errorf("sparse profile %s lists no directories", name)
+	}
+	return SparseProfile{Name: name, Dirs: dirs, Define: !slices.Equal(cfg.SparseProfiles[name], dirs)}, nil
+}
+
+// worktreeAddArgs builds a git worktree add command line. With a sparse
+// profile the worktree is created without a checkout, so applySparseCheckout
+// can narrow it before any file is written.
+func worktreeAddArgs(gitArgs []string, profile SparseProfile, args ...string) []string {
+	cmd := append(slices.Clone(gitArgs), "worktree", "add")
+	if len(profile.Dirs) > 0 {
+		cmd = append(cmd, "--no-checkout")
+	}
+	return append(cmd, args...)
+}
+
+// applySparseCheckout enables cone-mode sparse checkout for profile in a
+// worktree created by worktreeAddArgs and checks it out. git keeps the
+// setting per worktree, so other worktrees stay full checkouts.
+func applySparseCheckout(ctx context.Context, worktree string, profile SparseProfile) error {
+	if len(profile.Dirs) == 0 {
+		return nil
+	}
+	args := append([]string{"-C", worktree, "sparse-checkout", "set", "--cone", "--"}, profile.Dirs...)
+	if err := runGit(ctx, args...); err != nil {
+		return fmt.Errorf("apply sparse profile %s: %w", profile.Name, err)
+	}
+	if err := runGit(ctx, "-C", worktree, "checkout", "--quiet"); err != nil {
+		return fmt.Errorf("check out sparse profile %s: %w", profile.Name, err)
+	}
+	return nil
+}
+
+// saveSparseProfile records an inline profile definition in root/.gitsej.
+func saveSparseProfile(root string, profile SparseProfile) error {
+	if !profile.Define {
+		return nil
+	}
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(sparseKeyPrefix+profile.Name, strings.Join(profile.Dirs, ",")); err != nil {
+		return err
+	}
+	return cfg.Save(root)
+}
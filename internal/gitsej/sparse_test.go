@@ -0,0 +1,165 @@
+package gitsej
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreateAndAddWithSparseProfile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := newSparseTestRepo(t, ctx, filepath.Join(base, "origin"))
+	runGitTest(t, ctx, "-C", origin, "branch", "develop")
+
+	repoDir := filepath.Join(base, "repo")
+	if _, err := Create(ctx, CreateOptions{
+		RepoURL:      origin,
+		Directory:    repoDir,
+		MainWorktree: true,
+		Sparse:       "backend=services/api/,libs/go",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var cfg Config
+	if err := cfg.Load(repoDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(cfg.SparseProfiles["backend"], ","); got != "services/api,libs/go" {
+		t.Fatalf("sparse.backend = %q, want services/api,libs/go", got)
+	}
+	assertSparseFiles(t, filepath.Join(repoDir, "main"), "README.md", "libs/go/lib.go", "services/api/api.go")
+
+	develop, err := AddWorktree(ctx, AddWorktreeOptions{Directory: repoDir, Branch: "develop", Sparse: "backend"})
+	if err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+	if develop.SparseProfile != "backend" {
+		t.Fatalf("SparseProfile = %q, want backend", develop.SparseProfile)
+	}
+	assertSparseFiles(t, develop.Path, "README.md", "libs/go/lib.go", "services/api/api.go")
+
+	full, err := AddWorktree(ctx, AddWorktreeOptions{Directory: repoDir, Branch: "full"})
+	if err != nil {
+		t.Fatalf("AddWorktree(full): %v", err)
+	}
+	assertSparseFiles(t, full.Path, "README.md", "libs/go/lib.go", "services/api/api.go", "services/web/web.go")
+
+	web, err := AddWorktree(ctx, AddWorktreeOptions{Directory: repoDir, Branch: "web", Sparse: "web=services/web"})
+	if err != nil {
+		t.Fatalf("AddWorktree(web): %v", err)
+	}
+	assertSparseFiles(t, web.Path, "README.md", "services/web/web.go")
+	if err := cfg.Load(repoDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(cfg.SparseProfiles["web"], ","); got != "services/web" {
+		t.Fatalf("sparse.web = %q, want services/web", got)
+	}
+
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: repoDir, Branch: "other", Sparse: "frontend"}); err == nil || !strings.Contains(err.Error(), "unknown sparse profile") {
+		t.Fatalf("AddWorktree(unknown profile) error = %v", err)
+	}
+	if _, err := Create(ctx, CreateOptions{RepoURL: origin, Directory: filepath.Join(base, "named"), Sparse: "backend"}); err == nil {
+		t.Fatal("expected Create to reject a profile that is not defined inline")
+	}
+}
+
+func TestMigrateWithSparseProfile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repoDir := newSparseTestRepo(t, ctx, filepath.Join(t.TempDir(), "repo"))
+	writeTestFile(t, filepath.Join(repoDir, ".gitsej"), "sparse.backend=services/api\n")
+
+	if _, err := Migrate(ctx, MigrateOptions{Directory: repoDir, ForceMainClean: true, Sparse: "backend"}); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	assertSparseFiles(t, filepath.Join(repoDir, "main"), "README.md", "services/api/api.go")
+}
+
+func TestSparseProfileConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, ".gitsej"), "# profiles\nsparse.backend = /services/api/, libs/go,services/api\n")
+
+	var cfg Config
+	if err := cfg.Load(dir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(cfg.SparseProfiles["backend"], ","); got != "services/api,libs/go" {
+		t.Fatalf("sparse.backend = %q", got)
+	}
+	if err := cfg.Set("sparse.docs", "docs"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cfg.Set("sparse.bad", "../outside"); err == nil {
+		t.Fatal("expected a directory outside the repository to be rejected")
+	}
+	if err := cfg.Unset("sparse.backend"); err != nil {
+		t.Fatalf("Unset: %v", err)
+	}
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, ".gitsej"))
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+	if got, want := string(content), "# profiles\nsparse.docs=docs\n"; got != want {
+		t.Fatalf(".gitsej = %q, want %q", got, want)
+	}
+}
+
+// newSparseTestRepo creates a repository at dir with a few top-level
+// directories to check sparse profiles against.
+func newSparseTestRepo(t *testing.T, ctx context.Context, dir string) string {
+	t.Helper()
+
+	runGitTest(t, ctx, "init", "-b", "main", dir)
+	for _, file := range []string{"README.md", "services/api/api.go", "services/web/web.go", "libs/go/lib.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		writeTestFile(t, path, file+"\n")
+	}
+	runGitTest(t, ctx, "-C", dir, "add", ".")
+	runGitTest(t, ctx, "-C", dir, "commit", "-m", "init")
+	return dir
+}
+
+func assertSparseFiles(t *testing.T, worktree string, want ...string) {
+	t.Helper()
+
+	var got []string
+	err := filepath.WalkDir(worktree, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			// A worktree's .git is a file; only the path is skipped.
+			return nil
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(worktree, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", worktree, err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Fatalf("files in %s = %v, want %v", worktree, got, want)
+	}
+}
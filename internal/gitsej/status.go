@@ -0,0 +1,264 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MainStatusOptions struct {
+	Root     string
+	CacheDir string
+	Force    bool
+	Update   bool
+	// CacheOnly returns the cached state without fetching, for roots that a
+	// running gitsej daemon keeps fresh. Force and Update still fetch.
+	CacheOnly         bool
+	DefaultCooldown   int
+	DefaultAutoUpdate bool
+	// DefaultMainBranch and DefaultMainWorktree apply when .gitsej does not
+	// set main_branch or main_worktree.
+	DefaultMainBranch   string
+	DefaultMainWorktree string
+	Now                 time.Time
+}
+
+type MainStatusResult struct {
+	Root         string
+	Label        string
+	MainBranch   string
+	MainWorktree string
+	Behind       int
+	Dirty        bool
+	Refreshed    bool
+	CheckedAt    time.Time
+}
+
+var ErrMainWorktreeMissing = errors.New("main worktree does not exist")
+
+// MainStatus reports how far the configured main worktree of a gitsej root is
+// behind origin and whether it is dirty. Results are cached in opts.CacheDir
+// and only recomputed (with a fetch) once the configured cooldown has passed,
+// or when Force or Update is set. Update, or auto_update=1 in .gitsej, pulls
+// the main worktree when it is clean and behind.
+func MainStatus(ctx context.Context, opts MainStatusOptions) (MainStatusResult, error) {
+	root := strings.TrimSpace(opts.Root)
+	if root == "" {
+		return MainStatusResult{}, errors.New("gitsej root is required")
+	}
+
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return MainStatusResult{}, err
+	}
+
+	result := MainStatusResult{
+		Root:         root,
+		Label:        filepath.Base(root),
+		MainBranch:   cfg.MainBranch,
+		MainWorktree: cfg.MainWorktreePath(root),
+	}
+	if cfg.Label != "" {
+		result.Label = cfg.Label
+	}
+	// Like the tmux script, an empty main_branch= or main_worktree= falls
+	// back to the default as if the key were missing.
+	if branch := strings.TrimSpace(opts.DefaultMainBranch); branch != "" && !cfg.hasValue("main_branch") {
+		result.MainBranch = branch
+	}
+	if !cfg.hasValue("main_worktree") {
+		if dir, err := normalizeMainWorktree(opts.DefaultMainWorktree); err == nil && dir != "" {
+			result.MainWorktree = resolveMainWorktreePath(root, dir)
+		}
+	}
+
+	cooldown := opts.DefaultCooldown
+	if cfg.Has("cooldown") {
+		cooldown = cfg.Cooldown
+	}
+	autoUpdate := opts.DefaultAutoUpdate
+	if cfg.Has("auto_update") {
+		autoUpdate = cfg.AutoUpdate
+	}
+
+	if _, err := os.Stat(filepath.Join(result.MainWorktree, ".git")); err != nil {
+		return result, ErrMainWorktreeMissing
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	statePath := mainStatusStatePath(opts.CacheDir, root)
+	state := readMainStatusState(statePath)
+	result.Behind = state.Behind
+	result.Dirty = state.Dirty
+	result.CheckedAt = state.Last
+
+	if !opts.Force && !opts.Update && (opts.CacheOnly || now.Sub(state.Last) < time.Duration(cooldown)*time.Second) {
+		return result, nil
+	}
+
+	// A background refresh gives way to whoever holds the root and keeps
+	// the cached result; an explicit one waits like any other command.
+	background := !opts.Force && !opts.Update
+	wait := time.Duration(cfg.LockTimeout) * time.Second
+	if background {
+		wait = 0
+	}
+	lock, err := lockGitDir(ctx, filepath.Join(root, ".bare"), "status", wait)
+	if err != nil {
+		var busy *RootBusyError
+		if background && errors.As(err, &busy) {
+			return result, nil
+		}
+		return result, err
+	}
+	defer lock.Unlock()
+
+	fetchArgs := append([]string{"-C", result.MainWorktree, "fetch", "--all", "--prune"}, cloneFetchArgs(cfg)...)
+	if err := runGit(ctx, fetchArgs...); err != nil {
+		return result, err
+	}
+
+	result.Behind, result.Dirty = mainWorktreeState(ctx, result.MainWorktree, result.MainBranch)
+	if result.Behind > 0 && !result.Dirty && (opts.Update || autoUpdate) {
+		_ = runGit(ctx, "-C", result.MainWorktree, "pull", "--ff-only", "origin", result.MainBranch)
+		result.Behind, result.Dirty = mainWorktreeState(ctx, result.MainWorktree, result.MainBranch)
+	}
+	result.Refreshed = true
+	result.CheckedAt = now
+
+	if err := writeMainStatusState(statePath, mainStatusState{
+		Last:   now,
+		Behind: result.Behind,
+		Dirty:  result.Dirty,
+	}); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// DefaultStatusCacheDir returns the directory holding main status state files.
+func DefaultStatusCacheDir() string {
+	if cacheHome := strings.TrimSpace(os.Getenv("XDG_CACHE_HOME")); cacheHome != "" {
+		return filepath.Join(cacheHome, "gitsej-tmux")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "gitsej-tmux")
+	}
+	return filepath.Join(home, ".cache", "gitsej-tmux")
+}
+
+func mainWorktreeState(ctx context.Context, mainWorktree, mainBranch string) (int, bool) {
+	behind := 0
+	out, err := runGitOutput(ctx, "-C", mainWorktree, "rev-list", "--count", mainBranch+"..origin/"+mainBranch)
+	if err == nil {
+		if value, err := strconv.Atoi(strings.TrimSpace(out)); err == nil {
+			behind = value
+		}
+	}
+
+	dirty, err := isWorktreeDirty(ctx, mainWorktree)
+	if err != nil {
+		dirty = false
+	}
+	return behind, dirty
+}
+
+type mainStatusState struct {
+	Last   time.Time
+	Behind int
+	Dirty  bool
+}
+
+func mainStatusStatePath(cacheDir, root string) string {
+	if strings.TrimSpace(cacheDir) == "" {
+		cacheDir = DefaultStatusCacheDir()
+	}
+	return filepath.Join(cacheDir, fmt.Sprintf("main_status_%08x.env", crc32.ChecksumIEEE([]byte(root))))
+}
+
+func readMainStatusState(path string) mainStatusState {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return mainStatusState{}
+	}
+
+	state := mainStatusState{}
+	values := parseStateValues(string(content))
+	if last, err := strconv.ParseInt(values["last"], 10, 64); err == nil && last > 0 {
+		state.Last = time.Unix(last, 0)
+	}
+	if behind, err := strconv.Atoi(values["behind"]); err == nil && behind >= 0 {
+		state.Behind = behind
+	}
+	state.Dirty = values["dirty"] == "1"
+	return state
+}
+
+func writeMainStatusState(path string, state mainStatusState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create status cache directory: %w", err)
+	}
+
+	dirty := 0
+	if state.Dirty {
+		dirty = 1
+	}
+	content := fmt.Sprintf("last=%d\nbehind=%d\ndirty=%d\n", state.Last.Unix(), state.Behind, dirty)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write status cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write status cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write status cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write status cache: %w", err)
+	}
+	return nil
+}
+
+// ParseBool parses a boolean .gitsej value; 1, true, yes and on are true.
+func ParseBool(value string) bool {
+	parsed, _ := parseConfigBool(value)
+	return parsed
+}
+
+// ParseCooldown parses a cooldown in seconds from the environment, falling
+// back to the default for anything but a non-negative integer.
+func ParseCooldown(value string) int {
+	cooldown, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || cooldown < 0 {
+		return defaultCooldown
+	}
+	return cooldown
+}
+
+func parseStateValues(content string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || key == "" {
+			continue
+		}
+		values[key] = value
+	}
+	return values
+}
@@ -0,0 +1,184 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMainStatusFetchesAndUpdatesMainWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	cacheDir := t.TempDir()
+
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	origin := filepath.Join(filepath.Dir(root), "origin")
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "upstream")
+
+	now := time.Unix(1_700_000_000, 0)
+	result, err := MainStatus(ctx, MainStatusOptions{
+		Root:            root,
+		CacheDir:        cacheDir,
+		DefaultCooldown: 300,
+		Now:             now,
+	})
+	if err != nil {
+		t.Fatalf("MainStatus: %v", err)
+	}
+	if !result.Refreshed || result.Behind != 1 || result.Dirty {
+		t.Fatalf("unexpected status after first refresh: %+v", result)
+	}
+	if got, want := result.Label, filepath.Base(root); got != want {
+		t.Fatalf("result.Label = %q, want %q", got, want)
+	}
+
+	cached, err := MainStatus(ctx, MainStatusOptions{
+		Root:            root,
+		CacheDir:        cacheDir,
+		DefaultCooldown: 300,
+		Now:             now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("MainStatus(cached): %v", err)
+	}
+	if cached.Refreshed || cached.Behind != 1 {
+		t.Fatalf("expected cached status within cooldown, got %+v", cached)
+	}
+
+	updated, err := MainStatus(ctx, MainStatusOptions{
+		Root:     root,
+		CacheDir: cacheDir,
+		Update:   true,
+		Now:      now.Add(2 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("MainStatus(update): %v", err)
+	}
+	if !updated.Refreshed || updated.Behind != 0 {
+		t.Fatalf("expected update to pull main worktree, got %+v", updated)
+	}
+
+	log, err := runGitTestOutput(ctx, "-C", mainWorktree.Path, "log", "-1", "--format=%s")
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	if strings.TrimSpace(log) != "upstream" {
+		t.Fatalf("main worktree HEAD = %q, want upstream commit", strings.TrimSpace(log))
+	}
+}
+
+func TestMainStatusUsesConfigLabelAndReportsDirty(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mainWorktree.Path, "scratch.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitsej"), []byte("label=api\nmain_branch=main\n"), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	result, err := MainStatus(ctx, MainStatusOptions{Root: root, CacheDir: t.TempDir(), Force: true})
+	if err != nil {
+		t.Fatalf("MainStatus: %v", err)
+	}
+	if result.Label != "api" || !result.Dirty {
+		t.Fatalf("unexpected status: %+v", result)
+	}
+}
+
+func TestMainStatusRequiresMainWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	_, err := MainStatus(ctx, MainStatusOptions{Root: root, CacheDir: t.TempDir()})
+	if !errors.Is(err, ErrMainWorktreeMissing) {
+		t.Fatalf("expected ErrMainWorktreeMissing, got %v", err)
+	}
+}
+
+func TestMainStatusUsesDefaultsForUnsetKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	develop, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop", Path: "dev"})
+	if err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitsej"), []byte("label=api\n"), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	result, err := MainStatus(ctx, MainStatusOptions{
+		Root:                root,
+		CacheDir:            t.TempDir(),
+		Force:               true,
+		DefaultMainBranch:   "develop",
+		DefaultMainWorktree: "dev",
+	})
+	if err != nil {
+		t.Fatalf("MainStatus: %v", err)
+	}
+	if result.MainBranch != "develop" || result.MainWorktree != develop.Path {
+		t.Fatalf("unexpected status: %+v", result)
+	}
+}
+
+func TestMainStatusUsesDefaultsForEmptyKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+
+	develop, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop", Path: "dev"})
+	if err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+	// The tmux script ignored empty values and kept its defaults.
+	if err := os.WriteFile(filepath.Join(root, ".gitsej"), []byte("main_branch=\nmain_worktree=\n"), 0o644); err != nil {
+		t.Fatalf("write .gitsej: %v", err)
+	}
+
+	result, err := MainStatus(ctx, MainStatusOptions{
+		Root:                root,
+		CacheDir:            t.TempDir(),
+		Force:               true,
+		DefaultMainBranch:   "develop",
+		DefaultMainWorktree: "dev",
+	})
+	if err != nil {
+		t.Fatalf("MainStatus: %v", err)
+	}
+	if result.MainBranch != "develop" || result.MainWorktree != develop.Path {
+		t.Fatalf("unexpected status: %+v", result)
+	}
+}
+
+func TestParseCooldown(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string]int{"": 300, "60": 60, " 0 ": 0, "abc": 300, "-5": 300} {
+		if got := ParseCooldown(value); got != want {
+			t.Fatalf("ParseCooldown(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
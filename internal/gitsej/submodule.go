@@ -0,0 +1,104 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type submoduleInfo struct {
+	Name string
+	Path string
+}
+
+// initSubmodules checks out the submodules of a newly created worktree. When
+// a module repository is kept in .bare/modules, which is where a migrated
+// clone's modules end up, it is used as the clone source so nothing has to be
+// fetched from the network; the submodule's origin is then synced back to the
+// URL in .gitmodules.
+func initSubmodules(ctx context.Context, root, worktree string) error {
+	submodules, err := listSubmodules(ctx, worktree)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range submodules {
+		args := []string{"-C", worktree}
+		local := filepath.Join(root, ".bare", "modules", filepath.FromSlash(sub.Name))
+		hasLocal := false
+		if info, err := os.Stat(local); err == nil && info.IsDir() {
+			hasLocal = true
+			args = append(args,
+				"-c", "protocol.file.allow=always",
+				"-c", "submodule."+sub.Name+".url="+local,
+			)
+		}
+		args = append(args, "submodule", "update", "--init", "--", sub.Path)
+		if err := runGit(ctx, args...); err != nil {
+			return fmt.Errorf("init submodule %s: %w", sub.Path, err)
+		}
+		if hasLocal {
+			if err := runGit(ctx, "-C", worktree, "submodule", "sync", "--", sub.Path); err != nil {
+				return fmt.Errorf("sync submodule %s: %w", sub.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// listSubmodules reads the submodules declared in a worktree's .gitmodules.
+func listSubmodules(ctx context.Context, worktree string) ([]submoduleInfo, error) {
+	if _, err := os.Stat(filepath.Join(worktree, ".gitmodules")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("check .gitmodules in %s: %w", worktree, err)
+	}
+
+	// git config exits non-zero when nothing matches.
+	out, err := runGitOutput(ctx, "-C", worktree, "config", "-f", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	if err != nil {
+		return nil, nil
+	}
+
+	submodules := make([]submoduleInfo, 0)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		key, path, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "submodule."), ".path")
+		submodules = append(submodules, submoduleInfo{Name: name, Path: path})
+	}
+	return submodules, nil
+}
+
+// worktreeHasSubmodules reports whether worktree has checked out submodules,
+// which makes git refuse to move it.
+func worktreeHasSubmodules(ctx context.Context, worktree string) (bool, error) {
+	gitDir, err := runGitOutput(ctx, "-C", worktree, "rev-parse", "--path-format=absolute", "--git-dir")
+	if err != nil {
+		return false, err
+	}
+	if info, err := os.Stat(filepath.Join(strings.TrimSpace(gitDir), "modules")); err == nil && info.IsDir() {
+		return true, nil
+	}
+
+	out, err := runGitOutput(ctx, "-C", worktree, "ls-files", "--stage", "-z")
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range strings.Split(out, "\x00") {
+		meta, path, ok := strings.Cut(entry, "\t")
+		if !ok || !strings.HasPrefix(meta, "160000 ") {
+			continue
+		}
+		if pathExists(filepath.Join(worktree, path, ".git")) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
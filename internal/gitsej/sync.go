@@ -0,0 +1,138 @@
+package gitsej
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type SyncStatus string
+
+const (
+	SyncUpdated    SyncStatus = "updated"
+	SyncUpToDate   SyncStatus = "up-to-date"
+	SyncDirty      SyncStatus = "skipped-dirty"
+	SyncDiverged   SyncStatus = "diverged"
+	SyncNoUpstream SyncStatus = "no-upstream"
+	SyncDetached   SyncStatus = "detached"
+	SyncMissing    SyncStatus = "missing"
+	SyncFailed     SyncStatus = "failed"
+)
+
+type SyncOptions struct {
+	Directory string
+}
+
+type SyncResult struct {
+	Root      string
+	Worktrees []WorktreeSync
+}
+
+// WorktreeSync is the outcome of syncing one worktree. Commits is the number
+// of commits fast-forwarded, or the number behind when the worktree was
+// skipped; Ahead counts local commits not on the upstream.
+type WorktreeSync struct {
+	Path     string
+	Branch   string
+	Upstream string
+	Status   SyncStatus
+	Commits  int
+	Ahead    int
+	Err      error
+}
+
+// Count returns the number of worktrees with the given status.
+func (r SyncResult) Count(status SyncStatus) int {
+	n := 0
+	for _, wt := range r.Worktrees {
+		if wt.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Sync fetches every remote of the gitsej root containing opts.Directory once,
+// then fast-forwards each clean worktree's branch to its upstream. Dirty,
+// diverged, detached and untracked worktrees are left alone and reported.
+func Sync(ctx context.Context, opts SyncOptions) (SyncResult, error) {
+	root, err := ResolveRoot(ctx, opts.Directory)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	lock, err := lockRoot(ctx, root, "sync")
+	if err != nil {
+		return SyncResult{}, err
+	}
+	defer lock.Unlock()
+
+	fetchArgs := append([]string{"-C", root, "fetch", "--all", "--prune"}, cloneFetchArgs(cfg)...)
+	if err := runGit(ctx, fetchArgs...); err != nil {
+		return SyncResult{}, fmt.Errorf("fetch: %w", err)
+	}
+
+	worktrees, err := listWorktrees(ctx, root)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	result := SyncResult{Root: root}
+	for _, wt := range worktrees {
+		if wt.Bare {
+			continue
+		}
+		result.Worktrees = append(result.Worktrees, syncWorktree(ctx, wt))
+	}
+	return result, nil
+}
+
+func syncWorktree(ctx context.Context, wt worktreeInfo) WorktreeSync {
+	report := WorktreeSync{Path: wt.Path, Branch: strings.TrimPrefix(wt.Branch, "refs/heads/")}
+
+	if _, err := os.Stat(wt.Path); err != nil || wt.Prunable {
+		report.Status = SyncMissing
+		return report
+	}
+	if wt.Detached || wt.Branch == "" {
+		report.Status = SyncDetached
+		return report
+	}
+
+	report.Upstream, report.Ahead, report.Commits = upstreamDivergence(ctx, wt.Path)
+	if report.Upstream == "" {
+		report.Status = SyncNoUpstream
+		return report
+	}
+	if report.Commits == 0 {
+		report.Status = SyncUpToDate
+		return report
+	}
+	if report.Ahead > 0 {
+		report.Status = SyncDiverged
+		return report
+	}
+
+	dirty, err := isWorktreeDirty(ctx, wt.Path)
+	if err != nil {
+		report.Status = SyncFailed
+		report.Err = err
+		return report
+	}
+	if dirty {
+		report.Status = SyncDirty
+		return report
+	}
+
+	if err := runGit(ctx, "-C", wt.Path, "merge", "--ff-only", "--quiet", "@{upstream}"); err != nil {
+		report.Status = SyncFailed
+		report.Err = err
+		return report
+	}
+	report.Status = SyncUpdated
+	return report
+}
@@ -0,0 +1,85 @@
+package gitsej
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncFastForwardsCleanWorktrees(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	origin := filepath.Join(filepath.Dir(root), "origin")
+	runGitTest(t, ctx, "-C", origin, "branch", "diverged")
+	runGitTest(t, ctx, "-C", root, "fetch", "origin")
+
+	for _, branch := range []string{"main", "develop", "diverged", "feature"} {
+		if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: branch}); err != nil {
+			t.Fatalf("AddWorktree(%s): %v", branch, err)
+		}
+	}
+	runGitTest(t, ctx, "-C", root, "worktree", "add", "--detach", filepath.Join(root, "detached"), "main")
+
+	for _, branch := range []string{"main", "develop", "diverged"} {
+		runGitTest(t, ctx, "-C", origin, "checkout", "-q", branch)
+		runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "upstream "+branch)
+	}
+	runGitTest(t, ctx, "-C", origin, "checkout", "-q", "main")
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "upstream main again")
+	writeTestFile(t, filepath.Join(root, "develop", "README.md"), "local edit\n")
+	runGitTest(t, ctx, "-C", filepath.Join(root, "diverged"), "commit", "--allow-empty", "-m", "local")
+
+	result, err := Sync(ctx, SyncOptions{Directory: filepath.Join(root, "feature")})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	got := make(map[string]WorktreeSync, len(result.Worktrees))
+	for _, wt := range result.Worktrees {
+		got[filepath.Base(wt.Path)] = wt
+	}
+	want := map[string]SyncStatus{
+		"main":     SyncUpdated,
+		"develop":  SyncDirty,
+		"diverged": SyncDiverged,
+		"feature":  SyncNoUpstream,
+		"detached": SyncDetached,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("synced %d worktrees, want %d: %+v", len(got), len(want), result.Worktrees)
+	}
+	for name, status := range want {
+		if got[name].Status != status {
+			t.Fatalf("%s status = %s, want %s (%+v)", name, got[name].Status, status, got[name])
+		}
+	}
+	if got["main"].Commits != 2 {
+		t.Fatalf("main fast-forwarded %d commits, want 2", got["main"].Commits)
+	}
+	if got["diverged"].Ahead != 1 || got["diverged"].Commits != 1 {
+		t.Fatalf("diverged ahead/behind = %d/%d, want 1/1", got["diverged"].Ahead, got["diverged"].Commits)
+	}
+
+	head, err := runGitTestOutput(ctx, "-C", filepath.Join(root, "main"), "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	upstream, err := runGitTestOutput(ctx, "-C", origin, "rev-parse", "main")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	if strings.TrimSpace(head) != strings.TrimSpace(upstream) {
+		t.Fatalf("main HEAD = %s, want %s", strings.TrimSpace(head), strings.TrimSpace(upstream))
+	}
+
+	again, err := Sync(ctx, SyncOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Sync(again): %v", err)
+	}
+	if n := again.Count(SyncUpToDate); n != 1 {
+		t.Fatalf("second sync: %d up to date, want 1 (%+v)", n, again.Worktrees)
+	}
+}
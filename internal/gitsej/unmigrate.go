@@ -0,0 +1,520 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// unmigrateStashName is the directory in the repository holding the main
+// worktree between taking it out of the root and finishing the unmigration.
+const unmigrateStashName = "gitsej-unmigrate-stash"
+
+type UnmigrateOptions struct {
+	Directory      string
+	ForceMainClean bool
+	KeepWorktrees  bool
+}
+
+type UnmigrateResult struct {
+	Directory      string
+	Branch         string
+	MovedWorktrees []string
+	KeptWorktrees  []string
+	RemovedConfig  bool
+}
+
+// Unmigrate converts a gitsej root back into a standard clone: the main
+// worktree's branch is checked out in the root itself and .bare becomes .git
+// again. Other linked worktrees inside the root are moved next to it, or kept
+// in place with opts.KeepWorktrees, and stay linked to the clone. Everything is
+// checked before the root is touched, and a step that still fails rolls the
+// root back to its gitsej layout.
+func Unmigrate(ctx context.Context, opts UnmigrateOptions) (UnmigrateResult, error) {
+	root, err := ResolveRoot(ctx, opts.Directory)
+	if err != nil {
+		return UnmigrateResult{}, err
+	}
+	bareDir := filepath.Join(root, ".bare")
+	gitPath := filepath.Join(root, ".git")
+
+	if info, err := os.Stat(gitPath); err != nil || info.IsDir() {
+		return UnmigrateResult{}, fmt.Errorf(".git in %s is not a gitdir file", root)
+	}
+	lock, err := lockRoot(ctx, root, "unmigrate")
+	if err != nil {
+		return UnmigrateResult{}, err
+	}
+	defer lock.Unlock()
+
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return UnmigrateResult{}, err
+	}
+	mainPath := canonicalPath(cfg.MainWorktreePath(root))
+
+	worktrees, err := listWorktrees(ctx, bareDir)
+	if err != nil {
+		return UnmigrateResult{}, err
+	}
+
+	branch := cfg.MainBranch
+	var mainWorktree *worktreeInfo
+	linked := make([]worktreeInfo, 0, len(worktrees))
+	for i, wt := range worktrees {
+		if wt.Bare {
+			continue
+		}
+		if canonicalPath(wt.Path) == mainPath {
+			mainWorktree = &worktrees[i]
+			continue
+		}
+		linked = append(linked, wt)
+	}
+
+	if mainWorktree != nil {
+		if _, err := os.Stat(mainWorktree.Path); err == nil {
+			dirty, err := isWorktreeDirty(ctx, mainWorktree.Path)
+			if err != nil {
+				return UnmigrateResult{}, err
+			}
+			if dirty && !opts.ForceMainClean {
+				return UnmigrateResult{Directory: root}, &DirtyMainWorktreeError{Path: mainWorktree.Path}
+			}
+		}
+		if mainWorktree.Branch != "" {
+			branch = mainWorktree.Branch
+		}
+	}
+	if !gitRefExists(ctx, bareDir, "refs/heads/"+branch) {
+		return UnmigrateResult{}, fmt.Errorf("branch %s does not exist in %s", branch, root)
+	}
+	for _, wt := range linked {
+		if wt.Branch == branch {
+			return UnmigrateResult{}, fmt.Errorf("branch %s is checked out in worktree %s; remove it first", branch, wt.Path)
+		}
+	}
+
+	plan := unmigratePlan{root: root, branch: branch}
+	if mainWorktree != nil {
+		plan.mainWorktree = mainWorktree.Path
+		plan.mainAdminDir, _ = worktreeAdminDir(bareDir, mainWorktree.Path)
+	}
+
+	// Everything left in the root, apart from gitsej metadata and worktrees,
+	// must not collide with the files about to be checked out. .gitsej-hooks
+	// is left behind after unmigrating, so it is checked like any other file.
+	skip := map[string]struct{}{".bare": {}, ".git": {}, ".gitsej": {}}
+	if rel, err := filepath.Rel(canonicalPath(root), mainPath); err == nil && !strings.HasPrefix(rel, "..") {
+		top, _, _ := strings.Cut(rel, string(os.PathSeparator))
+		skip[top] = struct{}{}
+	}
+	used := make(map[string]struct{})
+	for _, wt := range linked {
+		if _, err := os.Stat(wt.Path); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(canonicalPath(root), canonicalPath(wt.Path))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			plan.repair = append(plan.repair, wt.Path)
+			continue
+		}
+		top, _, _ := strings.Cut(rel, string(os.PathSeparator))
+		if opts.KeepWorktrees {
+			skip[top] = struct{}{}
+			plan.kept = append(plan.kept, wt.Path)
+			plan.repair = append(plan.repair, wt.Path)
+			continue
+		}
+
+		// git refuses to move worktrees with checked out submodules.
+		submodules, err := worktreeHasSubmodules(ctx, wt.Path)
+		if err != nil {
+			return UnmigrateResult{}, err
+		}
+		if submodules {
+			return UnmigrateResult{}, fmt.Errorf("worktree %s contains submodules and cannot be moved; remove it or use --keep-worktrees", wt.Path)
+		}
+		name := filepath.Base(wt.Path)
+		if prefix := filepath.Base(root) + "-"; !strings.HasPrefix(name, prefix) {
+			name = prefix + name
+		}
+		dest, err := nextWorktreeDestination(filepath.Dir(root), name, used)
+		if err != nil {
+			return UnmigrateResult{}, err
+		}
+		used[dest] = struct{}{}
+		plan.moves = append(plan.moves, WorktreeMove{From: wt.Path, To: dest, Locked: wt.Locked})
+		plan.repair = append(plan.repair, dest)
+	}
+
+	tracked, err := runGitOutput(ctx, "--git-dir", bareDir, "ls-tree", "-z", "--name-only", "refs/heads/"+branch)
+	if err != nil {
+		return UnmigrateResult{}, err
+	}
+	plan.tracked = strings.FieldsFunc(tracked, func(r rune) bool { return r == 0 })
+	plan.trackedConfig = slices.Contains(plan.tracked, ".gitsej")
+	hadConfig := pathExists(filepath.Join(root, ".gitsej"))
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return UnmigrateResult{}, fmt.Errorf("read directory %s: %w", root, err)
+	}
+	movedTops := make(map[string]struct{}, len(plan.moves))
+	for _, move := range plan.moves {
+		rel, _ := filepath.Rel(canonicalPath(root), canonicalPath(move.From))
+		top, _, _ := strings.Cut(rel, string(os.PathSeparator))
+		movedTops[top] = struct{}{}
+	}
+	for _, entry := range entries {
+		if _, ok := skip[entry.Name()]; ok {
+			continue
+		}
+		if _, ok := movedTops[entry.Name()]; ok {
+			continue
+		}
+		if slices.Contains(plan.tracked, entry.Name()) {
+			return UnmigrateResult{}, fmt.Errorf("%s would be overwritten by checking out %s; move it out of %s first", filepath.Join(root, entry.Name()), branch, root)
+		}
+	}
+
+	steps := unmigrateSteps(plan, lock)
+	if err := applyUnmigrateSteps(ctx, steps); err != nil {
+		return UnmigrateResult{}, err
+	}
+
+	// Nothing below can be rolled back: the main worktree, its registration
+	// and the gitsej metadata are removed for good.
+	result := UnmigrateResult{Directory: root, Branch: branch}
+	if plan.mainAdminDir != "" {
+		if err := os.RemoveAll(filepath.Join(gitPath, "worktrees", plan.mainAdminDir)); err != nil {
+			return result, fmt.Errorf("unregister main worktree %s: %w", plan.mainWorktree, err)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(gitPath, unmigrateStashName)); err != nil {
+		return result, fmt.Errorf("remove main worktree %s: %w", plan.mainWorktree, err)
+	}
+	if plan.trackedConfig {
+		// gitsej's copy went with the stash; the checked out one stays.
+		result.RemovedConfig = hadConfig
+	} else if err := os.Remove(filepath.Join(root, ".gitsej")); err == nil {
+		result.RemovedConfig = true
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return result, fmt.Errorf("remove .gitsej: %w", err)
+	}
+	// The lock moved to .git with the repository; a standard clone has no
+	// use for it. It is released first, as Windows cannot remove open files.
+	lock.Unlock()
+	if err := os.Remove(filepath.Join(gitPath, lockFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, fmt.Errorf("remove lock file: %w", err)
+	}
+
+	for _, move := range plan.moves {
+		result.MovedWorktrees = append(result.MovedWorktrees, move.To)
+	}
+	slices.Sort(result.MovedWorktrees)
+	result.KeptWorktrees = plan.kept
+	slices.Sort(result.KeptWorktrees)
+	return result, nil
+}
+
+// unmigratePlan is what Unmigrate checked before touching the root.
+type unmigratePlan struct {
+	root         string
+	branch       string
+	mainWorktree string
+	mainAdminDir string
+	moves        []WorktreeMove
+	kept         []string
+	// repair lists the linked worktrees, at their final paths, that are
+	// pointed at .git once .bare has been renamed.
+	repair  []string
+	tracked []string
+	// trackedConfig is set when the branch tracks its own .gitsej, which
+	// replaces gitsej's copy on checkout.
+	trackedConfig bool
+}
+
+type unmigrateStep struct {
+	name     string
+	apply    func(ctx context.Context, step *journalStep) error
+	rollback func(ctx context.Context, step journalStep) error
+}
+
+// unmigrateSteps lists the steps of an unmigration in order. Like migrate's
+// steps, every rollback tolerates a step that only partially ran. lock is
+// released while .bare is renamed.
+func unmigrateSteps(plan unmigratePlan, lock *rootLock) []unmigrateStep {
+	root := plan.root
+	gitPath := filepath.Join(root, ".git")
+	barePath := filepath.Join(root, ".bare")
+
+	steps := make([]unmigrateStep, 0, len(plan.moves)+6)
+	for _, move := range plan.moves {
+		steps = append(steps, unmigrateStep{
+			name: "move-worktree:" + move.From,
+			apply: func(ctx context.Context, _ *journalStep) error {
+				if err := runGit(ctx, worktreeMoveArgs(barePath, move.Locked, move.From, move.To)...); err != nil {
+					return fmt.Errorf("move worktree %s to %s: %w", move.From, move.To, err)
+				}
+				return nil
+			},
+			rollback: func(ctx context.Context, _ journalStep) error {
+				if pathExists(move.From) || !pathExists(move.To) {
+					return nil
+				}
+				return runGit(ctx, worktreeMoveArgs(barePath, move.Locked, move.To, move.From)...)
+			},
+		})
+	}
+
+	// The main worktree is parked inside the repository rather than removed,
+	// so a rollback can put it back with its untracked and ignored files.
+	if plan.mainWorktree != "" {
+		parked := filepath.Join(barePath, unmigrateStashName, "main")
+		steps = append(steps, unmigrateStep{
+			name: "park-main-worktree",
+			apply: func(_ context.Context, step *journalStep) error {
+				if !pathExists(plan.mainWorktree) {
+					return nil
+				}
+				if err := os.MkdirAll(filepath.Dir(parked), 0o755); err != nil {
+					return fmt.Errorf("create %s: %w", filepath.Dir(parked), err)
+				}
+				if err := os.Rename(plan.mainWorktree, parked); err != nil {
+					return fmt.Errorf("remove main worktree %s: %w", plan.mainWorktree, err)
+				}
+				step.Value = parked
+				return nil
+			},
+			rollback: func(_ context.Context, step journalStep) error {
+				if step.Value == "" || !pathExists(parked) {
+					return nil
+				}
+				if err := os.Rename(parked, plan.mainWorktree); err != nil {
+					return fmt.Errorf("restore main worktree %s: %w", plan.mainWorktree, err)
+				}
+				return nil
+			},
+		})
+	}
+
+	steps = append(steps, []unmigrateStep{
+		{
+			name: "convert-bare",
+			apply: func(ctx context.Context, _ *journalStep) error {
+				if err := os.Remove(gitPath); err != nil {
+					return fmt.Errorf("remove .git file: %w", err)
+				}
+				if err := lock.rename(ctx, barePath, gitPath); err != nil {
+					return fmt.Errorf("move .bare to .git: %w", err)
+				}
+				return nil
+			},
+			rollback: func(ctx context.Context, _ journalStep) error {
+				if info, err := os.Stat(gitPath); err == nil && info.IsDir() {
+					if err := lock.rename(ctx, gitPath, barePath); err != nil {
+						return fmt.Errorf("move .git back to .bare: %w", err)
+					}
+				}
+				if !pathExists(gitPath) {
+					if err := os.WriteFile(gitPath, []byte(gitdirFileContent()), 0o644); err != nil {
+						return fmt.Errorf("write .git: %w", err)
+					}
+				}
+				if len(plan.repair) > 0 {
+					args := append([]string{"--git-dir", barePath, "worktree", "repair"}, plan.repair...)
+					if err := runGit(ctx, args...); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "core-bare",
+			apply: func(ctx context.Context, _ *journalStep) error {
+				return runGit(ctx, "--git-dir", gitPath, "config", "core.bare", "false")
+			},
+			rollback: func(ctx context.Context, _ journalStep) error {
+				return runGit(ctx, "--git-dir", migrateGitDir(root), "config", "core.bare", "true")
+			},
+		},
+	}...)
+
+	// gitsej's .gitsej is parked with the main worktree so that checking out
+	// the branch's own .gitsej cannot overwrite it before a rollback.
+	if plan.trackedConfig {
+		configPath := filepath.Join(root, ".gitsej")
+		parked := filepath.Join(gitPath, unmigrateStashName, ".gitsej")
+		steps = append(steps, unmigrateStep{
+			name: "park-config",
+			apply: func(_ context.Context, step *journalStep) error {
+				if !pathExists(configPath) {
+					return nil
+				}
+				if err := os.MkdirAll(filepath.Dir(parked), 0o755); err != nil {
+					return fmt.Errorf("create %s: %w", filepath.Dir(parked), err)
+				}
+				if err := os.Rename(configPath, parked); err != nil {
+					return fmt.Errorf("park .gitsej: %w", err)
+				}
+				step.Value = parked
+				return nil
+			},
+			rollback: func(_ context.Context, step journalStep) error {
+				if step.Value == "" {
+					return nil
+				}
+				parked := filepath.Join(migrateGitDir(root), unmigrateStashName, ".gitsej")
+				if !pathExists(parked) {
+					return nil
+				}
+				if err := os.Rename(parked, configPath); err != nil {
+					return fmt.Errorf("restore .gitsej: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	steps = append(steps, []unmigrateStep{
+		{
+			// Paths records the top-level entries the checkout creates, which
+			// the collision check above guarantees did not exist before.
+			name: "checkout",
+			apply: func(ctx context.Context, step *journalStep) error {
+				if head, err := runGitOutput(ctx, "--git-dir", gitPath, "symbolic-ref", "HEAD"); err == nil {
+					step.Value = strings.TrimSpace(head)
+				}
+				for _, name := range plan.tracked {
+					if !pathExists(filepath.Join(root, name)) {
+						step.Paths = append(step.Paths, name)
+					}
+				}
+				if err := runGit(ctx, "-C", root, "symbolic-ref", "HEAD", "refs/heads/"+plan.branch); err != nil {
+					return err
+				}
+				if err := runGit(ctx, "-C", root, "reset", "--hard", "--quiet"); err != nil {
+					return fmt.Errorf("check out %s in %s: %w", plan.branch, root, err)
+				}
+				return nil
+			},
+			rollback: func(ctx context.Context, step journalStep) error {
+				for _, name := range step.Paths {
+					if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+						return fmt.Errorf("remove checked out %s: %w", name, err)
+					}
+				}
+				gitDir := migrateGitDir(root)
+				if err := os.Remove(filepath.Join(gitDir, "index")); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("remove index: %w", err)
+				}
+				if step.Value == "" {
+					return nil
+				}
+				if head, err := runGitOutput(ctx, "--git-dir", gitDir, "symbolic-ref", "HEAD"); err == nil && strings.TrimSpace(head) == step.Value {
+					return nil
+				}
+				return runGit(ctx, "--git-dir", gitDir, "symbolic-ref", "HEAD", step.Value)
+			},
+		},
+	}...)
+
+	// Every linked worktree still points at .bare; repair them against .git.
+	// Rolling back convert-bare repairs them against .bare again.
+	if len(plan.repair) > 0 {
+		steps = append(steps, unmigrateStep{
+			name: "repair-worktrees",
+			apply: func(ctx context.Context, _ *journalStep) error {
+				args := append([]string{"-C", root, "worktree", "repair"}, plan.repair...)
+				return runGit(ctx, args...)
+			},
+		})
+	}
+
+	if len(plan.kept) > 0 {
+		excludePath := filepath.Join(gitPath, "info", "exclude")
+		steps = append(steps, unmigrateStep{
+			name: "exclude-kept-worktrees",
+			apply: func(_ context.Context, step *journalStep) error {
+				if content, err := os.ReadFile(excludePath); err == nil {
+					step.Value = string(content)
+				}
+				return excludeKeptWorktrees(root, plan.kept)
+			},
+			rollback: func(_ context.Context, step journalStep) error {
+				if step.Value == "" {
+					if err := os.Remove(excludePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+						return fmt.Errorf("restore %s: %w", excludePath, err)
+					}
+					return nil
+				}
+				if err := os.WriteFile(excludePath, []byte(step.Value), 0o644); err != nil {
+					return fmt.Errorf("restore %s: %w", excludePath, err)
+				}
+				return nil
+			},
+		})
+	}
+
+	return steps
+}
+
+// applyUnmigrateSteps applies steps in order. When one fails, it and the
+// steps before it are rolled back in reverse, restoring the gitsej root.
+func applyUnmigrateSteps(ctx context.Context, steps []unmigrateStep) error {
+	applied := make([]journalStep, 0, len(steps))
+	for _, step := range steps {
+		entry := journalStep{Name: step.name}
+		err := step.apply(ctx, &entry)
+		entry.Done = err == nil
+		applied = append(applied, entry)
+		if err == nil {
+			continue
+		}
+
+		for i := len(applied) - 1; i >= 0; i-- {
+			if steps[i].rollback == nil {
+				continue
+			}
+			if rollbackErr := steps[i].rollback(ctx, applied[i]); rollbackErr != nil {
+				return fmt.Errorf("%w; rollback failed: roll back %s: %v", err, applied[i].Name, rollbackErr)
+			}
+		}
+		return fmt.Errorf("%w; unmigration rolled back", err)
+	}
+	return nil
+}
+
+// excludeKeptWorktrees adds worktrees left inside the checkout to
+// .git/info/exclude so they do not show up as untracked directories.
+func excludeKeptWorktrees(root string, kept []string) error {
+	if len(kept) == 0 {
+		return nil
+	}
+
+	excludePath := filepath.Join(root, ".git", "info", "exclude")
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(excludePath), err)
+	}
+	f, err := os.OpenFile(excludePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", excludePath, err)
+	}
+	defer f.Close()
+
+	for _, path := range kept {
+		rel, err := filepath.Rel(canonicalPath(root), canonicalPath(path))
+		if err != nil {
+			continue
+		}
+		if _, err := fmt.Fprintf(f, "/%s/\n", filepath.ToSlash(rel)); err != nil {
+			return fmt.Errorf("write %s: %w", excludePath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package gitsej
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnmigrateRestoresStandardClone(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"}); err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+
+	result, err := Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Unmigrate: %v", err)
+	}
+	if result.Branch != "main" || !result.RemovedConfig {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if info, err := os.Stat(filepath.Join(root, ".git")); err != nil || !info.IsDir() {
+		t.Fatalf("expected .git directory, err=%v", err)
+	}
+	for _, name := range []string{".bare", ".gitsej", "main", "develop", filepath.Join(".git", lockFileName)} {
+		if _, err := os.Stat(filepath.Join(root, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be gone, stat err=%v", name, err)
+		}
+	}
+
+	branch, err := runGitTestOutput(ctx, "-C", root, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	if strings.TrimSpace(branch) != "main" {
+		t.Fatalf("root HEAD = %q, want main", strings.TrimSpace(branch))
+	}
+	status, err := runGitTestOutput(ctx, "-C", root, "status", "--porcelain")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if strings.TrimSpace(status) != "" {
+		t.Fatalf("expected clean checkout in root, got %q", status)
+	}
+
+	moved := filepath.Join(filepath.Dir(root), "repo-develop")
+	if len(result.MovedWorktrees) != 1 || result.MovedWorktrees[0] != moved {
+		t.Fatalf("result.MovedWorktrees = %v, want [%s]", result.MovedWorktrees, moved)
+	}
+	common, err := runGitTestOutput(ctx, "-C", moved, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		t.Fatalf("moved worktree is broken: %v", err)
+	}
+	if got, want := canonicalPath(strings.TrimSpace(common)), filepath.Join(root, ".git"); got != want {
+		t.Fatalf("moved worktree common dir = %q, want %q", got, want)
+	}
+}
+
+func TestUnmigrateKeepsWorktreesInPlace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"}); err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+
+	result, err := Unmigrate(ctx, UnmigrateOptions{Directory: root, KeepWorktrees: true})
+	if err != nil {
+		t.Fatalf("Unmigrate: %v", err)
+	}
+	if got, want := strings.Join(result.KeptWorktrees, ","), filepath.Join(root, "develop"); got != want {
+		t.Fatalf("result.KeptWorktrees = %q, want %q", got, want)
+	}
+
+	branch, err := runGitTestOutput(ctx, "-C", filepath.Join(root, "develop"), "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("kept worktree is broken: %v", err)
+	}
+	if strings.TrimSpace(branch) != "develop" {
+		t.Fatalf("kept worktree HEAD = %q, want develop", strings.TrimSpace(branch))
+	}
+	status, err := runGitTestOutput(ctx, "-C", root, "status", "--porcelain")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if strings.TrimSpace(status) != "" {
+		t.Fatalf("expected kept worktree to be excluded from root status, got %q", status)
+	}
+}
+
+func TestUnmigrateRequiresConfirmationWhenMainIsDirty(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	writeTestFile(t, filepath.Join(mainWorktree.Path, "README.md"), "changed\n")
+
+	_, err = Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	var dirtyErr *DirtyMainWorktreeError
+	if !errors.As(err, &dirtyErr) {
+		t.Fatalf("expected DirtyMainWorktreeError, got %T (%v)", err, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".bare")); err != nil {
+		t.Fatalf("expected root to be untouched: %v", err)
+	}
+
+	if _, err := Unmigrate(ctx, UnmigrateOptions{Directory: root, ForceMainClean: true}); err != nil {
+		t.Fatalf("Unmigrate(force): %v", err)
+	}
+}
+
+func TestUnmigrateChecksOutMainWorktreeBranch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	runGitTest(t, ctx, "-C", mainWorktree.Path, "switch", "develop")
+
+	result, err := Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Unmigrate: %v", err)
+	}
+	if result.Branch != "develop" {
+		t.Fatalf("result.Branch = %q, want develop", result.Branch)
+	}
+	branch, err := runGitTestOutput(ctx, "-C", root, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	if strings.TrimSpace(branch) != "develop" {
+		t.Fatalf("root HEAD = %q, want develop", strings.TrimSpace(branch))
+	}
+}
+
+func TestUnmigrateRefusesBranchCheckedOutInLinkedWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	// With main_worktree pointing elsewhere, the main branch is held by an
+	// ordinary linked worktree and cannot be checked out in the root.
+	if _, err := SetConfig(ctx, ConfigOptions{Directory: root, Key: "main_worktree", Value: "trunk"}); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+
+	_, err := Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err == nil || !strings.Contains(err.Error(), "branch main is checked out in worktree") {
+		t.Fatalf("Unmigrate error = %v, want branch checked out error", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".bare")); err != nil {
+		t.Fatalf("expected root to be untouched: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "main", "README.md")); err != nil {
+		t.Fatalf("expected worktree to be untouched: %v", err)
+	}
+}
+
+func TestUnmigrateMovesLockedWorktree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	develop, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"})
+	if err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+	runGitTest(t, ctx, "-C", root, "worktree", "lock", "--reason", "on usb disk", develop.Path)
+
+	result, err := Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Unmigrate: %v", err)
+	}
+	moved := filepath.Join(filepath.Dir(root), "repo-develop")
+	if len(result.MovedWorktrees) != 1 || result.MovedWorktrees[0] != moved {
+		t.Fatalf("result.MovedWorktrees = %v, want [%s]", result.MovedWorktrees, moved)
+	}
+	worktrees, err := listWorktrees(ctx, root)
+	if err != nil {
+		t.Fatalf("listWorktrees: %v", err)
+	}
+	for _, wt := range worktrees {
+		if canonicalPath(wt.Path) == moved && (!wt.Locked || wt.LockReason != "on usb disk") {
+			t.Fatalf("moved worktree lost its lock: %+v", wt)
+		}
+	}
+}
+
+func TestUnmigrateRefusesToMoveWorktreeWithSubmodules(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"}); err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	if _, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"}); err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+	// git treats a modules directory in the worktree's admin entry as
+	// checked out submodules.
+	if err := os.Mkdir(filepath.Join(root, ".bare", "worktrees", "develop", "modules"), 0o755); err != nil {
+		t.Fatalf("create modules directory: %v", err)
+	}
+
+	_, err := Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err == nil || !strings.Contains(err.Error(), "contains submodules") {
+		t.Fatalf("Unmigrate error = %v, want submodules error", err)
+	}
+	for _, name := range []string{".bare", ".gitsej", filepath.Join("main", "README.md"), filepath.Join("develop", "README.md")} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("expected root to be untouched: %v", err)
+		}
+	}
+
+	if _, err := Unmigrate(ctx, UnmigrateOptions{Directory: root, KeepWorktrees: true}); err != nil {
+		t.Fatalf("Unmigrate(keep worktrees): %v", err)
+	}
+}
+
+func TestUnmigrateRollsBackOnFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	develop, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "develop"})
+	if err != nil {
+		t.Fatalf("AddWorktree(develop): %v", err)
+	}
+	writeTestFile(t, filepath.Join(mainWorktree.Path, "build.log"), "untracked\n")
+	// A stale HEAD.lock makes checking out the branch in the root fail after
+	// the worktrees have been moved and .bare renamed.
+	headLock := filepath.Join(root, ".bare", "HEAD.lock")
+	writeTestFile(t, headLock, "")
+
+	_, err = Unmigrate(ctx, UnmigrateOptions{Directory: root, ForceMainClean: true})
+	if err == nil || !strings.Contains(err.Error(), "unmigration rolled back") {
+		t.Fatalf("Unmigrate error = %v, want rolled back error", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(root, ".git")); err != nil || info.IsDir() {
+		t.Fatalf("expected .git file to be restored, err=%v", err)
+	}
+	for _, path := range []string{
+		filepath.Join(root, ".bare"),
+		filepath.Join(root, ".gitsej"),
+		filepath.Join(mainWorktree.Path, "build.log"),
+		filepath.Join(develop.Path, "README.md"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to be restored: %v", path, err)
+		}
+	}
+	for _, path := range []string{filepath.Join(root, "README.md"), filepath.Join(filepath.Dir(root), "repo-develop")} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be gone, stat err=%v", path, err)
+		}
+	}
+	for _, wt := range []string{mainWorktree.Path, develop.Path} {
+		status, err := runGitTestOutput(ctx, "-C", wt, "status", "--porcelain")
+		if err != nil {
+			t.Fatalf("worktree %s is broken: %v", wt, err)
+		}
+		if wt == develop.Path && strings.TrimSpace(status) != "" {
+			t.Fatalf("worktree %s status = %q, want clean", wt, status)
+		}
+	}
+
+	if err := os.Remove(headLock); err != nil {
+		t.Fatalf("remove HEAD.lock: %v", err)
+	}
+	if _, err := Unmigrate(ctx, UnmigrateOptions{Directory: root, ForceMainClean: true}); err != nil {
+		t.Fatalf("Unmigrate after rollback: %v", err)
+	}
+}
+
+func TestUnmigrateKeepsTrackedConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	writeTestFile(t, filepath.Join(mainWorktree.Path, ".gitsej"), "label=tracked\n")
+	runGitTest(t, ctx, "-C", mainWorktree.Path, "add", ".gitsej")
+	runGitTest(t, ctx, "-C", mainWorktree.Path,
+		"-c", "user.name=gitsej-test",
+		"-c", "user.email=gitsej-test@example.invalid",
+		"commit", "-m", "track .gitsej")
+	configPath := filepath.Join(root, ".gitsej")
+	ours, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read .gitsej: %v", err)
+	}
+
+	// A rollback puts gitsej's own .gitsej back, not the branch's.
+	headLock := filepath.Join(root, ".bare", "HEAD.lock")
+	writeTestFile(t, headLock, "")
+	if _, err := Unmigrate(ctx, UnmigrateOptions{Directory: root}); err == nil {
+		t.Fatal("expected Unmigrate to fail with HEAD.lock in place")
+	}
+	if data, err := os.ReadFile(configPath); err != nil || string(data) != string(ours) {
+		t.Fatalf("expected gitsej's .gitsej to be restored, got %q (err=%v)", data, err)
+	}
+	if err := os.Remove(headLock); err != nil {
+		t.Fatalf("remove HEAD.lock: %v", err)
+	}
+
+	result, err := Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err != nil {
+		t.Fatalf("Unmigrate: %v", err)
+	}
+	if !result.RemovedConfig {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if data, err := os.ReadFile(configPath); err != nil || string(data) != "label=tracked\n" {
+		t.Fatalf("expected tracked .gitsej to stay, got %q (err=%v)", data, err)
+	}
+	status, err := runGitTestOutput(ctx, "-C", root, "status", "--porcelain")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if strings.TrimSpace(status) != "" {
+		t.Fatalf("expected clean checkout in root, got %q", status)
+	}
+}
+
+func TestUnmigrateRefusesToOverwriteHooksDir(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newTestGitsejRoot(t, ctx)
+	mainWorktree, err := AddWorktree(ctx, AddWorktreeOptions{Directory: root, Branch: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree(main): %v", err)
+	}
+	for _, dir := range []string{filepath.Join(mainWorktree.Path, hooksDirName), filepath.Join(root, hooksDirName)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("create %s: %v", dir, err)
+		}
+	}
+	writeTestFile(t, filepath.Join(mainWorktree.Path, hooksDirName, postWorktreeAddHook), "#!/bin/sh\n")
+	runGitTest(t, ctx, "-C", mainWorktree.Path, "add", hooksDirName)
+	runGitTest(t, ctx, "-C", mainWorktree.Path,
+		"-c", "user.name=gitsej-test",
+		"-c", "user.email=gitsej-test@example.invalid",
+		"commit", "-m", "track hooks")
+	writeTestFile(t, filepath.Join(root, hooksDirName, postWorktreeAddHook), "#!/bin/sh\necho local\n")
+
+	_, err = Unmigrate(ctx, UnmigrateOptions{Directory: root})
+	if err == nil || !strings.Contains(err.Error(), "would be overwritten") {
+		t.Fatalf("Unmigrate error = %v, want collision error", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".bare")); err != nil {
+		t.Fatalf("expected root to be untouched: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package gitsej
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -10,18 +11,23 @@ import (
 )
 
 type UpgradeOptions struct {
-	Directory  string
-	MainBranch string
+	Directory       string
+	MainBranch      string
+	MainWorktreeDir string
 }
 
 type UpgradeResult struct {
-	Directory      string
-	CreatedGitFile bool
-	CreatedConfig  bool
-	AddedKeys      []string
+	Directory              string
+	CreatedGitFile         bool
+	CreatedConfig          bool
+	AddedKeys              []string
+	ConfiguredFetchRefspec bool
+	// FetchErr is set when the fetch after configuring the refspec failed,
+	// e.g. offline; origin/* refs appear on the next successful fetch.
+	FetchErr error
 }
 
-func Upgrade(opts UpgradeOptions) (UpgradeResult, error) {
+func Upgrade(ctx context.Context, opts UpgradeOptions) (UpgradeResult, error) {
 	targetDir := strings.TrimSpace(opts.Directory)
 	if targetDir == "" {
 		targetDir = "."
@@ -32,6 +38,11 @@ func Upgrade(opts UpgradeOptions) (UpgradeResult, error) {
 		mainBranch = "main"
 	}
 
+	mainWorktreeDir, err := normalizeMainWorktree(opts.MainWorktreeDir)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+
 	info, err := os.Stat(targetDir)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -53,6 +64,12 @@ func Upgrade(opts UpgradeOptions) (UpgradeResult, error) {
 		return UpgradeResult{}, fmt.Errorf(".bare is not a directory in %s", targetDir)
 	}
 
+	lock, err := lockRoot(ctx, targetDir, "upgrade")
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	defer lock.Unlock()
+
 	result := UpgradeResult{Directory: targetDir}
 
 	gitFile := filepath.Join(targetDir, ".git")
@@ -66,12 +83,20 @@ func Upgrade(opts UpgradeOptions) (UpgradeResult, error) {
 		result.CreatedGitFile = true
 	}
 
+	if hasOrigin, hasRefspec := hasOriginFetchRefspec(ctx, bareDir); hasOrigin && !hasRefspec {
+		if err := writeFetchRefspec(ctx, bareDir); err != nil {
+			return UpgradeResult{}, err
+		}
+		result.ConfiguredFetchRefspec = true
+		result.FetchErr = fetchOrigin(ctx, bareDir)
+	}
+
 	configFile := filepath.Join(targetDir, ".gitsej")
 	if _, err := os.Stat(configFile); err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			return UpgradeResult{}, fmt.Errorf("check .gitsej in %s: %w", targetDir, err)
 		}
-		if err := os.WriteFile(configFile, []byte(gitsejConfigContent(mainBranch)), 0o644); err != nil {
+		if err := os.WriteFile(configFile, []byte(gitsejConfigContent(mainBranch, mainWorktreeDir)), 0o644); err != nil {
 			return UpgradeResult{}, fmt.Errorf("write .gitsej: %w", err)
 		}
 		result.CreatedConfig = true
@@ -85,7 +110,7 @@ func Upgrade(opts UpgradeOptions) (UpgradeResult, error) {
 	content := string(contentBytes)
 
 	keys := parseConfigKeys(content)
-	additions, addedKeys := missingDefaultConfigAdditions(mainBranch, keys)
+	additions, addedKeys := missingDefaultConfigAdditions(mainBranch, mainWorktreeDir, keys)
 	if len(addedKeys) == 0 {
 		return result, nil
 	}
@@ -128,29 +153,25 @@ func parseConfigKeys(content string) map[string]struct{} {
 	return keys
 }
 
-func missingDefaultConfigAdditions(mainBranch string, existing map[string]struct{}) ([]string, []string) {
-	defaultLines := map[string][]string{
-		"label":         {"label="},
-		"main_worktree": {"main_worktree=main"},
-		"main_branch":   {fmt.Sprintf("main_branch=%s", mainBranch)},
-		"cooldown":      {"cooldown=300"},
-		"auto_update": {
-			"# 0 = never auto-pull, 1 = auto-pull when clean and behind.",
-			"auto_update=0",
-		},
-	}
-	order := []string{"label", "main_worktree", "main_branch", "cooldown", "auto_update"}
-
+func missingDefaultConfigAdditions(mainBranch, mainWorktree string, existing map[string]struct{}) ([]string, []string) {
 	lines := make([]string, 0, 8)
-	keys := make([]string, 0, len(order))
-	for _, key := range order {
-		if _, ok := existing[key]; ok {
+	keys := make([]string, 0, len(configSchema))
+	for _, key := range configSchema {
+		if _, ok := existing[key.Name]; ok || key.Optional {
 			continue
 		}
-		keys = append(keys, key)
-		lines = append(lines, defaultLines[key]...)
+		value := key.Default
+		switch key.Name {
+		case "main_branch":
+			value = mainBranch
+		case "main_worktree":
+			value = mainWorktree
+		}
+		keys = append(keys, key.Name)
+		if key.Comment != "" {
+			lines = append(lines, key.Comment)
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s", key.Name, value))
 	}
-	slices.Clip(lines)
-	slices.Clip(keys)
-	return lines, keys
+	return slices.Clip(lines), slices.Clip(keys)
 }
@@ -1,6 +1,7 @@
 package gitsej
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"slices"
@@ -16,7 +17,7 @@ func TestUpgradeCreatesMissingGitAndConfig(t *testing.T) {
 		t.Fatalf("mkdir .bare: %v", err)
 	}
 
-	result, err := Upgrade(UpgradeOptions{
+	result, err := Upgrade(context.Background(), UpgradeOptions{
 		Directory:  dir,
 		MainBranch: "trunk",
 	})
@@ -74,9 +75,10 @@ custom_key=keepme
 		t.Fatalf("write .gitsej: %v", err)
 	}
 
-	result, err := Upgrade(UpgradeOptions{
-		Directory:  dir,
-		MainBranch: "main",
+	result, err := Upgrade(context.Background(), UpgradeOptions{
+		Directory:       dir,
+		MainBranch:      "main",
+		MainWorktreeDir: "trunk",
 	})
 	if err != nil {
 		t.Fatalf("Upgrade: %v", err)
@@ -115,7 +117,7 @@ custom_key=keepme
 	if !strings.Contains(content, "label=\n") {
 		t.Fatalf("expected missing label key added, got:\n%s", content)
 	}
-	if !strings.Contains(content, "main_worktree=main\n") {
+	if !strings.Contains(content, "main_worktree=trunk\n") {
 		t.Fatalf("expected missing main_worktree key added, got:\n%s", content)
 	}
 	if !strings.Contains(content, "auto_update=0\n") {
@@ -150,7 +152,7 @@ auto_update=1
 		t.Fatalf("read .gitsej before: %v", err)
 	}
 
-	result, err := Upgrade(UpgradeOptions{
+	result, err := Upgrade(context.Background(), UpgradeOptions{
 		Directory:  dir,
 		MainBranch: "trunk",
 	})
@@ -169,3 +171,65 @@ auto_update=1
 		t.Fatalf("expected config unchanged; before:\n%s\nafter:\n%s", string(before), string(after))
 	}
 }
+
+func TestUpgradeRepairsMissingFetchRefspec(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "init")
+
+	dir := filepath.Join(base, "repo")
+	runGitTest(t, ctx, "clone", "--bare", origin, filepath.Join(dir, ".bare"))
+
+	result, err := Upgrade(ctx, UpgradeOptions{Directory: dir})
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if !result.ConfiguredFetchRefspec {
+		t.Fatalf("expected fetch refspec to be configured")
+	}
+	if !gitRefExists(ctx, dir, "refs/remotes/origin/main") {
+		t.Fatalf("expected origin/main after upgrade fetch")
+	}
+
+	again, err := Upgrade(ctx, UpgradeOptions{Directory: dir})
+	if err != nil {
+		t.Fatalf("Upgrade(again): %v", err)
+	}
+	if again.ConfiguredFetchRefspec {
+		t.Fatalf("did not expect refspec to be configured twice")
+	}
+}
+
+func TestUpgradeConfiguresFetchRefspecWhenOriginIsUnreachable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin")
+	runGitTest(t, ctx, "init", "-b", "main", origin)
+	runGitTest(t, ctx, "-C", origin, "commit", "--allow-empty", "-m", "init")
+
+	dir := filepath.Join(base, "repo")
+	runGitTest(t, ctx, "clone", "--bare", origin, filepath.Join(dir, ".bare"))
+	if err := os.RemoveAll(origin); err != nil {
+		t.Fatalf("remove origin: %v", err)
+	}
+
+	result, err := Upgrade(ctx, UpgradeOptions{Directory: dir})
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if !result.ConfiguredFetchRefspec || result.FetchErr == nil {
+		t.Fatalf("expected refspec configured and fetch error reported: %+v", result)
+	}
+	if !result.CreatedGitFile || !result.CreatedConfig {
+		t.Fatalf("expected metadata to be written despite the failed fetch: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitsej")); err != nil {
+		t.Fatalf("expected .gitsej: %v", err)
+	}
+}
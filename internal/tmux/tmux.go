@@ -0,0 +1,74 @@
+// Package tmux wraps the tmux commands gitsej uses to discover session panes
+// and pin a gitsej root per session.
+package tmux
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const RootOption = "@gitsej_root"
+
+// Available reports whether the tmux binary is on PATH.
+func Available() bool {
+	_, err := exec.LookPath("tmux")
+	return err == nil
+}
+
+// CurrentSession returns the id of the session tmux considers current.
+func CurrentSession(ctx context.Context) (string, error) {
+	return run(ctx, "display-message", "-p", "#{session_id}")
+}
+
+// ActivePanePath returns the current path of the active pane in session.
+func ActivePanePath(ctx context.Context, session string) (string, error) {
+	return run(ctx, "display-message", "-p", "-t", session, "#{pane_current_path}")
+}
+
+// PanePaths returns the current path of every pane in session, in tmux order.
+func PanePaths(ctx context.Context, session string) ([]string, error) {
+	out, err := run(ctx, "list-panes", "-a", "-F", "#{session_id}\t#{pane_current_path}")
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, 8)
+	for _, line := range strings.Split(out, "\n") {
+		sid, path, ok := strings.Cut(line, "\t")
+		if !ok || sid != session || strings.TrimSpace(path) == "" {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Option returns the value of a session option, or "" when it is unset.
+func Option(ctx context.Context, session, name string) string {
+	value, err := run(ctx, "show-options", "-t", session, "-vq", name)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+func SetOption(ctx context.Context, session, name, value string) error {
+	_, err := run(ctx, "set-option", "-t", session, "-q", name, value)
+	return err
+}
+
+func UnsetOption(ctx context.Context, session, name string) error {
+	_, err := run(ctx, "set-option", "-t", session, "-qu", name)
+	return err
+}
+
+func run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "tmux", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tmux %s failed: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}